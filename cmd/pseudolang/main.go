@@ -7,18 +7,62 @@ import (
 
 	"github.com/urfave/cli/v3"
 	"github.com/username/pseudolang/internal/commands"
+	"github.com/username/pseudolang/internal/config"
+	"github.com/username/pseudolang/internal/logging"
 )
 
+// closeLogFile closes the log file once the command is done
+var closeLogFile = func() error { return nil }
+
 func main() {
 	cmd := &cli.Command{
 		Name:    "pseudolang",
 		Version: "0.1.0",
 		Usage:   "A pseudolang interpreter",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				Usage:   "Path of the config file to use",
+				Sources: cli.EnvVars("PSEUDO_CONFIG"),
+			},
+			&cli.StringFlag{
+				Name:    "log-level",
+				Usage:   "Log the steps of each run at this level and above: debug, info, warn or error (default: info with --log-file, otherwise off)",
+				Sources: cli.EnvVars("PSEUDO_LOG_LEVEL"),
+			},
+			&cli.StringFlag{
+				Name:    "log-file",
+				Usage:   "Append the log to this file as JSON lines instead of writing it to stderr",
+				Sources: cli.EnvVars("PSEUDO_LOG_FILE"),
+			},
+		},
+		Before: func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+			config.SetPath(cmd.String("config"))
+
+			closeLog, err := logging.Setup(cmd.String("log-level"), cmd.String("log-file"))
+			if err != nil {
+				return ctx, err
+			}
+			closeLogFile = closeLog
+			return ctx, nil
+		},
+		After: func(ctx context.Context, cmd *cli.Command) error {
+			return closeLogFile()
+		},
 		Commands: []*cli.Command{
+			commands.InitCommand,
 			commands.RunCommand,
+			commands.BuildCommand,
+			commands.TestCommand,
 			commands.ExecCommand,
 			commands.ModelCommand,
+			commands.ModelsCommand,
 			commands.ProviderCommand,
+			commands.ConfigCommand,
+			commands.ProfileCommand,
+			commands.UsageCommand,
+			commands.HistoryCommand,
+			commands.ReplayCommand,
 		},
 	}
 
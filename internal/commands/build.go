@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	"github.com/username/pseudolang/internal/core"
+	"github.com/username/pseudolang/internal/project"
+)
+
+var BuildCommand = &cli.Command{
+	Name:      "build",
+	Usage:     "Translate a pseudolang program to Python without running it",
+	ArgsUsage: "[file]",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:    "out-dir",
+			Aliases: []string{"o"},
+			Usage:   "Directory to write the generated Python to (default: the project's output directory, or build)",
+		},
+		&cli.BoolFlag{
+			Name:  "chunked",
+			Usage: "Translate large inputs in pieces split at top-level definitions",
+		},
+		&cli.BoolFlag{
+			Name:  "incremental",
+			Usage: "Only re-translate top-level definitions that changed since the last build",
+		},
+		statsFlag,
+		maxCostFlag,
+		showAnalysisFlag,
+		dumpFlag,
+		outputFlag,
+	}, modelFlags...),
+	Action: buildAction,
+}
+
+func buildAction(ctx context.Context, cmd *cli.Command) error {
+	manifest, err := findProject()
+	if err != nil {
+		return err
+	}
+
+	filePath, err := entryFile(cmd.Args().First(), manifest)
+	if err != nil {
+		return err
+	}
+
+	program, err := loadProgram(filePath, manifest)
+	if err != nil {
+		return err
+	}
+
+	opts := projectOptions(manifest)
+	applyFlags(cmd, &opts.Overrides)
+	if err := applyRunFlags(cmd, &opts); err != nil {
+		return err
+	}
+	opts.Chunked = cmd.Bool("chunked")
+	opts.Incremental = cmd.Bool("incremental")
+
+	jsonOutput, outputDir, err := buildOutput(cmd)
+	if err != nil {
+		return err
+	}
+
+	// The record collects the model, usage and assumptions for JSON output
+	record := &core.RunRecord{}
+	opts.Record = record
+	// fail returns err, and prints the record with it when the output is
+	// JSON, so that scripts get a document whatever the outcome
+	fail := func(err error) error {
+		if jsonOutput {
+			record.Error = err.Error()
+			_ = record.WriteJSON(os.Stdout)
+		}
+		return err
+	}
+
+	start := time.Now()
+	modules, err := core.TranslateProgram(ctx, program, opts)
+	record.TranslateTime = time.Since(start)
+	if err != nil {
+		return fail(err)
+	}
+
+	if len(modules) == 1 {
+		record.Code = modules[program.Entry.Module]
+	} else {
+		record.Modules, record.Entry = modules, program.Entry.Module
+	}
+
+	if err := writeBuild(outputDir, manifest, program, modules, jsonOutput); err != nil {
+		return fail(err)
+	}
+	if jsonOutput {
+		return record.WriteJSON(os.Stdout)
+	}
+	return nil
+}
+
+// buildOutput returns whether --output asks for JSON and the directory given
+// with --out-dir. --output used to name the output directory, so a value that
+// is not a format is still taken as one, with a warning.
+func buildOutput(cmd *cli.Command) (bool, string, error) {
+	outputDir := cmd.String("out-dir")
+
+	switch format := cmd.String("output"); format {
+	case "", "text":
+		return false, outputDir, nil
+	case "json":
+		return true, outputDir, nil
+	default:
+		if outputDir != "" {
+			return false, "", fmt.Errorf("unknown output format %q (expected text or json)", format)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: --output now sets the output format; use --out-dir (-o) for the output directory\n")
+		return false, format, nil
+	}
+}
+
+// writeBuild writes the generated modules to outputDir, or the project's
+// output directory when it is empty, reporting what it wrote unless the output
+// is JSON
+func writeBuild(outputDir string, manifest *project.Manifest, program *core.Program, modules map[string]string, quiet bool) error {
+	if outputDir == "" {
+		outputDir = "build"
+		if manifest != nil {
+			outputDir = manifest.OutputDir()
+		}
+	}
+
+	if len(modules) == 1 {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+
+		path := filepath.Join(outputDir, program.Entry.Module+".py")
+		if err := os.WriteFile(path, []byte(modules[program.Entry.Module]), 0644); err != nil {
+			return fmt.Errorf("failed to write Python file: %w", err)
+		}
+
+		if !quiet {
+			fmt.Printf("Wrote %s\n", path)
+		}
+		return nil
+	}
+
+	if err := core.WritePythonPackage(outputDir, modules); err != nil {
+		return err
+	}
+	if quiet {
+		return nil
+	}
+
+	fmt.Printf("Wrote %d modules to %s\n", len(modules), filepath.Join(outputDir, core.PythonPackageName))
+	fmt.Printf("Run with: cd %s && python -m %s.%s\n", outputDir, core.PythonPackageName, program.Entry.Module)
+	return nil
+}
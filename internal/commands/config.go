@@ -0,0 +1,224 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v3"
+	"github.com/username/pseudolang/internal/config"
+)
+
+var ConfigCommand = &cli.Command{
+	Name:  "config",
+	Usage: "View and edit configuration settings",
+	Description: "Keys are JSON field names joined with dots, for example active_model, " +
+		"providers.openai.token or models.gpt-4.max_tokens.",
+	Commands: []*cli.Command{
+		{
+			Name:      "get",
+			Usage:     "Print the value of a key in the global config file",
+			ArgsUsage: "<key>",
+			Action:    configGetAction,
+		},
+		{
+			Name:      "set",
+			Usage:     "Set a key in the global config file",
+			ArgsUsage: "<key> <value>",
+			Action:    configSetAction,
+		},
+		{
+			Name:      "unset",
+			Usage:     "Remove a key from the global config file",
+			ArgsUsage: "<key>",
+			Action:    configUnsetAction,
+		},
+		{
+			Name:   "list",
+			Usage:  "List every key set in the global config file",
+			Action: configListAction,
+		},
+		{
+			Name:   "path",
+			Usage:  "Print the location of the global config file",
+			Action: configPathAction,
+		},
+		{
+			Name:   "edit",
+			Usage:  "Open the global config file in $VISUAL or $EDITOR",
+			Action: configEditAction,
+		},
+		{
+			Name:  "show",
+			Usage: "Show the effective configuration after merging every layer",
+			Description: "Layers are applied in order: built-in defaults, the global config file, " +
+				"the project manifest, environment variables, then command-line flags.",
+			Flags: append([]cli.Flag{
+				&cli.BoolFlag{
+					Name:  "origin",
+					Usage: "Show which layer each value came from",
+				},
+			}, modelFlags...),
+			Action: configShowAction,
+		},
+	},
+}
+
+func configGetAction(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("expected exactly 1 argument: <key>")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	key := cmd.Args().Get(0)
+	value, err := cfg.Get(key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(config.DisplayValue(key, value))
+	return nil
+}
+
+func configSetAction(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 2 {
+		return fmt.Errorf("expected exactly 2 arguments: <key> <value>")
+	}
+
+	key := cmd.Args().Get(0)
+
+	err := config.Update(func(cfg *config.Config) error {
+		return cfg.Set(key, cmd.Args().Get(1))
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Set %s\n", key)
+	return nil
+}
+
+func configUnsetAction(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("expected exactly 1 argument: <key>")
+	}
+
+	key := cmd.Args().Get(0)
+
+	err := config.Update(func(cfg *config.Config) error {
+		return cfg.Unset(key)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Unset %s\n", key)
+	return nil
+}
+
+func configListAction(ctx context.Context, cmd *cli.Command) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	values := cfg.Values()
+	for _, key := range config.SortedKeys(values) {
+		fmt.Printf("%s=%s\n", key, config.DisplayValue(key, values[key]))
+	}
+
+	return nil
+}
+
+func configPathAction(ctx context.Context, cmd *cli.Command) error {
+	path, err := config.Path()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(path)
+	return nil
+}
+
+func configEditAction(ctx context.Context, cmd *cli.Command) error {
+	path, err := config.Path()
+	if err != nil {
+		return err
+	}
+
+	// Make sure there is a file to open. A corrupt file is opened as it is,
+	// since editing is how it gets fixed.
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := config.Update(func(*config.Config) error { return nil }); err != nil {
+			return err
+		}
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	args := append(strings.Fields(editor), path)
+	editCmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	editCmd.Stdin = os.Stdin
+	editCmd.Stdout = os.Stdout
+	editCmd.Stderr = os.Stderr
+	if err := editCmd.Run(); err != nil {
+		return fmt.Errorf("editor failed: %w", err)
+	}
+
+	if _, err := config.Load(); err != nil {
+		return fmt.Errorf("config is no longer valid after editing: %w", err)
+	}
+
+	return nil
+}
+
+func configShowAction(ctx context.Context, cmd *cli.Command) error {
+	manifest, err := findProject()
+	if err != nil {
+		return err
+	}
+
+	overrides := projectOptions(manifest).Overrides
+	applyFlags(cmd, &overrides)
+
+	resolved, err := config.LoadResolved(overrides)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	values := resolved.Values()
+	for _, key := range []string{"active_model", "active_provider"} {
+		if _, ok := values[key]; !ok {
+			values[key] = ""
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, key := range config.SortedKeys(values) {
+		value := config.DisplayValue(key, values[key])
+		if value == "" {
+			value = "(not set)"
+		}
+
+		if cmd.Bool("origin") {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", key, value, resolved.Origin(key))
+		} else {
+			_, _ = fmt.Fprintf(w, "%s\t%s\n", key, value)
+		}
+	}
+
+	return w.Flush()
+}
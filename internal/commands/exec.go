@@ -13,13 +13,18 @@ var ExecCommand = &cli.Command{
 	Name:      "exec",
 	Usage:     "Execute a pseudolang string",
 	ArgsUsage: "<string>",
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		&cli.BoolFlag{
 			Name:    "verbose",
 			Aliases: []string{"v"},
 			Usage:   "Print the generated Python code before execution",
 		},
-	},
+		statsFlag,
+		maxCostFlag,
+		showAnalysisFlag,
+		dumpFlag,
+		outputFlag,
+	}, modelFlags...),
 	Action: execAction,
 }
 
@@ -31,6 +36,17 @@ func execAction(ctx context.Context, cmd *cli.Command) error {
 
 	userInput := strings.Join(args, " ")
 
-	verbose := cmd.Bool("verbose")
-	return core.ExecuteWithLLM(ctx, userInput, verbose)
+	opts := core.ExecuteOptions{
+		Verbose: cmd.Bool("verbose"),
+	}
+	applyFlags(cmd, &opts.Overrides)
+	if err := applyRunFlags(cmd, &opts); err != nil {
+		return err
+	}
+	jsonOutput, err := outputJSON(cmd)
+	if err != nil {
+		return err
+	}
+	opts.JSON = jsonOutput
+	return core.ExecuteWithLLM(ctx, userInput, opts)
 }
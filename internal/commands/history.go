@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	"github.com/username/pseudolang/internal/core"
+)
+
+var HistoryCommand = &cli.Command{
+	Name:  "history",
+	Usage: "List recent runs, or show one with 'history show <id>'",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:    "limit",
+			Aliases: []string{"n"},
+			Usage:   "Number of runs to list",
+			Value:   20,
+		},
+	},
+	Action: historyListAction,
+	Commands: []*cli.Command{
+		{
+			Name:      "show",
+			Usage:     "Show the details of a run, including its generated code and output",
+			ArgsUsage: "<id>",
+			Action:    historyShowAction,
+		},
+	},
+}
+
+var ReplayCommand = &cli.Command{
+	Name:      "replay",
+	Usage:     "Run the Python generated by an earlier run again, without calling the model",
+	ArgsUsage: "<id>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "Print the Python code before running it",
+		},
+	},
+	Action: replayAction,
+}
+
+func historyListAction(ctx context.Context, cmd *cli.Command) error {
+	history, err := core.DefaultHistory()
+	if err != nil {
+		return err
+	}
+
+	records, err := history.List(int(cmd.Int("limit")))
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	if len(records) == 0 {
+		fmt.Println("No runs recorded")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tSOURCE\tMODEL\tEXIT\tDURATION")
+	for _, r := range records {
+		source := r.Source
+		if source == "" {
+			source = "(exec)"
+		}
+		exit := fmt.Sprint(r.ExitCode)
+		if !r.HasCode() {
+			exit = "not translated"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.Time.Local().Format(time.DateTime), source, orDash(r.Model),
+			exit, (r.TranslateTime + r.ExecuteTime).Round(100*time.Millisecond))
+	}
+	return w.Flush()
+}
+
+func historyShowAction(ctx context.Context, cmd *cli.Command) error {
+	record, err := loadRecord(cmd)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "id:\t%s\n", record.ID)
+	fmt.Fprintf(w, "time:\t%s\n", record.Time.Local().Format(time.DateTime))
+	if record.Source != "" {
+		fmt.Fprintf(w, "source:\t%s\n", record.Source)
+	}
+	if record.Project != "" {
+		fmt.Fprintf(w, "project:\t%s\n", record.Project)
+	}
+	fmt.Fprintf(w, "input hash:\t%s\n", record.InputHash)
+	fmt.Fprintf(w, "model:\t%s/%s\n", orDash(record.Provider), orDash(record.Model))
+	fmt.Fprintf(w, "prompt version:\t%s\n", record.PromptVersion)
+	fmt.Fprintf(w, "translate time:\t%s\n", record.TranslateTime.Round(time.Millisecond))
+	fmt.Fprintf(w, "execute time:\t%s\n", record.ExecuteTime.Round(time.Millisecond))
+	for _, u := range record.Usage {
+		cost := "unknown"
+		if u.CostKnown {
+			cost = core.FormatCost(u.Cost)
+		}
+		fmt.Fprintf(w, "usage:\t%s/%s: %d requests, %d prompt + %d completion tokens, %s\n",
+			u.Provider, u.Model, u.Requests, u.PromptTokens, u.CompletionTokens, cost)
+	}
+	fmt.Fprintf(w, "exit code:\t%d\n", record.ExitCode)
+	if record.Error != "" {
+		fmt.Fprintf(w, "error:\t%s\n", record.Error)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if record.Code != "" {
+		printSection("Generated Python Code", record.Code)
+	}
+	modules := make([]string, 0, len(record.Modules))
+	for module := range record.Modules {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	for _, module := range modules {
+		printSection("Generated Python Module "+module, record.Modules[module])
+	}
+	if record.Stdout != "" {
+		printSection("Stdout", record.Stdout)
+	}
+	if record.Stderr != "" {
+		printSection("Stderr", record.Stderr)
+	}
+	return nil
+}
+
+func replayAction(ctx context.Context, cmd *cli.Command) error {
+	record, err := loadRecord(cmd)
+	if err != nil {
+		return err
+	}
+	if !record.HasCode() {
+		return fmt.Errorf("run %s did not generate any code: %s", record.ID, record.Error)
+	}
+
+	if cmd.Bool("verbose") {
+		if record.Code != "" {
+			printSection("Generated Python Code", record.Code)
+		}
+		for module, code := range record.Modules {
+			printSection("Generated Python Module "+module, code)
+		}
+	}
+
+	return record.Replay(ctx, record.Run.Options())
+}
+
+// loadRecord returns the run named by the command's only argument
+func loadRecord(cmd *cli.Command) (*core.RunRecord, error) {
+	if cmd.Args().Len() != 1 {
+		return nil, fmt.Errorf("expected exactly 1 argument: <id>")
+	}
+
+	history, err := core.DefaultHistory()
+	if err != nil {
+		return nil, err
+	}
+	return history.Get(cmd.Args().First())
+}
+
+func printSection(title, text string) {
+	fmt.Printf("\n--- %s ---\n", title)
+	fmt.Print(text)
+	if !strings.HasSuffix(text, "\n") {
+		fmt.Println()
+	}
+	fmt.Printf("--- End %s ---\n", title)
+}
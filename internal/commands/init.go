@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli/v3"
+	"github.com/username/pseudolang/internal/project"
+)
+
+const starterProgram = `function greet(name):
+    return "Hello, " + name + "!"
+
+print greet("World")
+`
+
+var InitCommand = &cli.Command{
+	Name:      "init",
+	Usage:     "Create a pseudolang project",
+	ArgsUsage: "[directory]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "name",
+			Usage: "Project name (default: the directory name)",
+		},
+		&cli.StringFlag{
+			Name:  "model",
+			Usage: "Default model for the project",
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "Overwrite an existing " + project.ManifestName,
+		},
+	},
+	Action: initAction,
+}
+
+func initAction(ctx context.Context, cmd *cli.Command) error {
+	dir := cmd.Args().First()
+	if dir == "" {
+		dir = "."
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	manifestPath := filepath.Join(dir, project.ManifestName)
+	if _, err := os.Stat(manifestPath); err == nil && !cmd.Bool("force") {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", manifestPath)
+	}
+
+	name := cmd.String("name")
+	if name == "" {
+		name = filepath.Base(dir)
+	}
+
+	manifest := project.New(name)
+	manifest.Model = cmd.String("model")
+	manifest.Dir = dir
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create project directory: %w", err)
+	}
+
+	if err := manifest.Save(); err != nil {
+		return err
+	}
+
+	for _, sub := range append(manifest.Sources, manifest.Test.Dir) {
+		if err := os.MkdirAll(manifest.Path(sub), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", sub, err)
+		}
+	}
+
+	entry := manifest.EntryPath()
+	if _, err := os.Stat(entry); os.IsNotExist(err) {
+		if err := os.WriteFile(entry, []byte(starterProgram), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", manifest.Entry, err)
+		}
+	}
+
+	fmt.Printf("Created project %s in %s\n", name, dir)
+	return nil
+}
@@ -10,13 +10,22 @@ import (
 
 var ModelCommand = &cli.Command{
 	Name:      "model",
-	Usage:     "Switch to a specific model (auto-detects provider)",
+	Usage:     "Switch to a specific model or alias (auto-detects provider, or use provider:model)",
 	ArgsUsage: "<model>",
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:  "token",
 			Usage: "API token for the model's provider",
 		},
+		&cli.IntFlag{
+			Name:  "max-tokens",
+			Usage: "Maximum output tokens to request from this model or alias",
+		},
+		&cli.BoolFlag{
+			Name:  "which",
+			Usage: "Show which provider the model routes to and why, without switching",
+		},
+		profileFlag,
 	},
 	Action: modelAction,
 }
@@ -29,33 +38,89 @@ func modelAction(ctx context.Context, cmd *cli.Command) error {
 	model := cmd.Args().Get(0)
 	token := cmd.String("token")
 
-	cfg, err := config.Load()
-	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+	if cmd.Bool("which") {
+		return explainRoute(cmd, model)
 	}
 
-	if token != "" {
-		if err := cfg.SetModelWithToken(model, token); err != nil {
-			return fmt.Errorf("failed to set model with token: %w", err)
+	var message string
+	err := config.Update(func(cfg *config.Config) error {
+		route, err := cfg.RouteModel(model)
+		if err != nil {
+			return fmt.Errorf("failed to switch to model: %w", err)
+		}
+		provider := route.Provider
+
+		if cmd.IsSet("max-tokens") {
+			if err := cfg.SetMaxTokens(route.SettingsName(), cmd.Int("max-tokens")); err != nil {
+				return fmt.Errorf("failed to set max tokens: %w", err)
+			}
+		}
+
+		if profile := cfg.SelectedProfile(cmd.String("profile")); profile != "" {
+			if err := cfg.SetProfileModel(profile, model, token); err != nil {
+				return fmt.Errorf("failed to switch to model: %w", err)
+			}
+			message = fmt.Sprintf("Switched profile %s to model %s (provider: %s)", profile, describeRoute(route), provider)
+			return nil
+		}
+
+		if token != "" {
+			if err := cfg.SetModelWithToken(model, token); err != nil {
+				return fmt.Errorf("failed to set model with token: %w", err)
+			}
+			message = fmt.Sprintf("Successfully configured %s (provider: %s) with new token", describeRoute(route), provider)
+			return nil
 		}
-		if err := cfg.Save(); err != nil {
-			return fmt.Errorf("failed to save config: %w", err)
+
+		if err := cfg.SetActiveModel(model); err != nil {
+			return fmt.Errorf("failed to switch to model: %w", err)
 		}
-		provider, _ := config.DetermineProvider(model)
-		fmt.Printf("Successfully configured %s (provider: %s) with new token\n", model, provider)
+		message = fmt.Sprintf("Switched to model %s (provider: %s)", describeRoute(route), provider)
 		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	if err := cfg.SetActiveModel(model); err != nil {
-		return fmt.Errorf("failed to switch to model: %w", err)
-	}
+	fmt.Println(message)
+	return nil
+}
+
+// explainRoute prints the provider model routes to and the rule that chose it
+func explainRoute(cmd *cli.Command, model string) error {
+	var overrides config.Overrides
+	applyFlags(cmd, &overrides)
 
-	if err := cfg.Save(); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
+	cfg, err := config.LoadResolved(overrides)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	provider, _ := config.DetermineProvider(model)
-	fmt.Printf("Switched to model %s (provider: %s)\n", model, provider)
+	route, err := cfg.RouteModel(model)
+	if err != nil {
+		return err
+	}
 
+	fmt.Printf("%s -> %s (model %s)\n", model, route.Provider, route.Model)
+	if route.Alias != "" {
+		fmt.Printf("  alias for %s\n", cfg.Aliases[route.Alias])
+	}
+	if route.Rule == nil {
+		fmt.Printf("  provider named explicitly with %q\n", route.Provider+":")
+		return nil
+	}
+	fmt.Printf("  matched %s\n", route.Rule)
+	for _, rule := range route.Shadowed {
+		fmt.Printf("  shadowed %s\n", rule)
+	}
 	return nil
 }
+
+// describeRoute names the model route selects, with the alias it was
+// selected by
+func describeRoute(route config.Route) string {
+	if route.Alias != "" {
+		return fmt.Sprintf("%s via alias %s", route.Model, route.Alias)
+	}
+	return route.Model
+}
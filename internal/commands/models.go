@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v3"
+	"github.com/username/pseudolang/internal/config"
+)
+
+var ModelsCommand = &cli.Command{
+	Name:  "models",
+	Usage: "Show the models pseudo knows about",
+	Commands: []*cli.Command{
+		{
+			Name:  "list",
+			Usage: "List the models in the registry",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "provider",
+					Usage: "Only list models for this provider",
+				},
+				profileFlag,
+			},
+			Action: modelsListAction,
+		},
+		{
+			Name:      "info",
+			Usage:     "Show the registry entry for a model",
+			ArgsUsage: "<model>",
+			Flags:     []cli.Flag{profileFlag},
+			Action:    modelsInfoAction,
+		},
+	},
+}
+
+func modelsListAction(ctx context.Context, cmd *cli.Command) error {
+	var overrides config.Overrides
+	overrides.Profile = cmd.String("profile")
+
+	cfg, err := config.LoadResolved(overrides)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  MODEL\tPROVIDER\tCONTEXT\tMAX OUTPUT\tINPUT $/M\tOUTPUT $/M\tCAPABILITIES")
+	for _, model := range cfg.RegisteredModels() {
+		info, _ := cfg.ModelInfo(model)
+		if provider := cmd.String("provider"); provider != "" && info.Provider != provider {
+			continue
+		}
+
+		marker := " "
+		if model == cfg.ActiveModel {
+			marker = "*"
+		}
+
+		fmt.Fprintf(w, "%s %s\t%s\t%s\t%s\t%s\t%s\t%s\n", marker, model, orDash(info.Provider),
+			formatCount(info.ContextWindow), formatCount(info.MaxOutputTokens),
+			formatPrice(info.InputPrice), formatPrice(info.OutputPrice), orDash(strings.Join(info.Capabilities, ", ")))
+	}
+	return w.Flush()
+}
+
+func modelsInfoAction(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("expected exactly 1 argument: <model>")
+	}
+
+	var overrides config.Overrides
+	overrides.Profile = cmd.String("profile")
+
+	cfg, err := config.LoadResolved(overrides)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	route, err := cfg.RouteModel(cmd.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	info, ok := cfg.ModelInfo(route.Model)
+	if !ok {
+		return fmt.Errorf("%s is not in the model registry (it would use provider %s); add it with 'pseudo config set models.%s.<field> <value>'", route.Model, route.Provider, route.Model)
+	}
+
+	capabilities := "unknown"
+	if info.Capabilities != nil {
+		capabilities = orDash(strings.Join(info.Capabilities, ", "))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "model:\t%s\n", route.Model)
+	if route.Alias != "" {
+		fmt.Fprintf(w, "alias:\t%s\n", route.Alias)
+	}
+	fmt.Fprintf(w, "provider:\t%s\n", route.Provider)
+	fmt.Fprintf(w, "context window:\t%s\n", formatCount(info.ContextWindow))
+	fmt.Fprintf(w, "max output tokens:\t%s\n", formatCount(info.MaxOutputTokens))
+	fmt.Fprintf(w, "max tokens used:\t%d\n", cfg.MaxTokens(route.SettingsName()))
+	fmt.Fprintf(w, "input price:\t%s\n", formatPricePerMillion(info.InputPrice))
+	fmt.Fprintf(w, "output price:\t%s\n", formatPricePerMillion(info.OutputPrice))
+	fmt.Fprintf(w, "capabilities:\t%s\n", capabilities)
+	return w.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// formatCount formats a token count, with "-" for unknown
+func formatCount(n int) string {
+	if n <= 0 {
+		return "-"
+	}
+	return strconv.Itoa(n)
+}
+
+// formatPrice formats a price in dollars, with "-" for unknown
+func formatPrice(dollars *float64) string {
+	if dollars == nil {
+		return "-"
+	}
+	return "$" + strconv.FormatFloat(*dollars, 'f', -1, 64)
+}
+
+func formatPricePerMillion(dollars *float64) string {
+	if dollars == nil {
+		return "unknown"
+	}
+	return formatPrice(dollars) + " per million tokens"
+}
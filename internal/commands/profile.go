@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v3"
+	"github.com/username/pseudolang/internal/config"
+)
+
+var ProfileCommand = &cli.Command{
+	Name:  "profile",
+	Usage: "Manage named config profiles",
+	Commands: []*cli.Command{
+		{
+			Name:      "create",
+			Usage:     "Create a profile",
+			ArgsUsage: "<name>",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "model",
+					Usage: "Active model of the profile",
+				},
+				&cli.StringFlag{
+					Name:  "token",
+					Usage: "API token for the model's provider, stored in the profile",
+				},
+				&cli.BoolFlag{
+					Name:  "use",
+					Usage: "Make the new profile the active one",
+				},
+			},
+			Action: profileCreateAction,
+		},
+		{
+			Name:      "use",
+			Usage:     "Make a profile the active one",
+			ArgsUsage: "<name>",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "none",
+					Usage: "Deactivate profiles and use the base config",
+				},
+			},
+			Action: profileUseAction,
+		},
+		{
+			Name:   "list",
+			Usage:  "List profiles",
+			Action: profileListAction,
+		},
+		{
+			Name:      "delete",
+			Usage:     "Delete a profile",
+			ArgsUsage: "<name>",
+			Action:    profileDeleteAction,
+		},
+	},
+}
+
+func profileCreateAction(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("expected exactly 1 argument: <name>")
+	}
+	name := cmd.Args().Get(0)
+
+	if cmd.String("token") != "" && cmd.String("model") == "" {
+		return fmt.Errorf("--token requires --model")
+	}
+
+	err := config.Update(func(cfg *config.Config) error {
+		if err := cfg.CreateProfile(name); err != nil {
+			return err
+		}
+
+		if model := cmd.String("model"); model != "" {
+			if err := cfg.SetProfileModel(name, model, cmd.String("token")); err != nil {
+				return fmt.Errorf("failed to set profile model: %w", err)
+			}
+		}
+
+		if cmd.Bool("use") {
+			return cfg.UseProfile(name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created profile %s\n", name)
+	return nil
+}
+
+func profileUseAction(ctx context.Context, cmd *cli.Command) error {
+	name := ""
+	if !cmd.Bool("none") {
+		if cmd.Args().Len() != 1 {
+			return fmt.Errorf("expected exactly 1 argument: <name>")
+		}
+		name = cmd.Args().Get(0)
+	}
+
+	err := config.Update(func(cfg *config.Config) error {
+		return cfg.UseProfile(name)
+	})
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		fmt.Println("Deactivated profiles")
+	} else {
+		fmt.Printf("Switched to profile %s\n", name)
+	}
+	return nil
+}
+
+func profileListAction(ctx context.Context, cmd *cli.Command) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if len(cfg.Profiles) == 0 {
+		fmt.Println("No profiles configured")
+		return nil
+	}
+
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		marker := " "
+		if name == cfg.ActiveProfile {
+			marker = "*"
+		}
+		model := cfg.Profiles[name].ActiveModel
+		if model == "" {
+			model = "(inherits model)"
+		}
+		fmt.Fprintf(w, "%s %s\t%s\n", marker, name, model)
+	}
+	return w.Flush()
+}
+
+func profileDeleteAction(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("expected exactly 1 argument: <name>")
+	}
+	name := cmd.Args().Get(0)
+
+	var vaultEntries []string
+	err := config.Update(func(cfg *config.Config) error {
+		var err error
+		vaultEntries, err = cfg.DeleteProfile(name)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// A profile created later under the same name must not find these tokens
+	for _, entry := range vaultEntries {
+		if err := config.VaultDelete(entry); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove %s from the vault: %v\n", entry, err)
+		}
+	}
+
+	fmt.Printf("Deleted profile %s\n", name)
+	return nil
+}
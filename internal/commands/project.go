@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/urfave/cli/v3"
+	"github.com/username/pseudolang/internal/config"
+	"github.com/username/pseudolang/internal/core"
+	"github.com/username/pseudolang/internal/project"
+)
+
+// modelFlags select the model for a single invocation
+var modelFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "model",
+		Usage: "Model to use for this run, overriding the configured one",
+	},
+	&cli.StringFlag{
+		Name:  "provider",
+		Usage: "Provider to use for this run (default: detected from the model)",
+	},
+	profileFlag,
+}
+
+// profileFlag selects a named profile, overriding the active one
+var profileFlag = &cli.StringFlag{
+	Name:  "profile",
+	Usage: "Named config profile to use (default: $PSEUDO_PROFILE, then the active profile)",
+}
+
+// statsFlag prints the tokens a translation used and their cost
+var statsFlag = &cli.BoolFlag{
+	Name:  "stats",
+	Usage: "Print the tokens used and their cost after translating",
+}
+
+// maxCostFlag replaces the per-run budget for a single invocation
+var maxCostFlag = &cli.FloatFlag{
+	Name:  "max-cost",
+	Usage: "Refuse or stop the run if it would cost more than this many US dollars (default: the budget.per_run setting)",
+}
+
+// showAnalysisFlag echoes the model's analysis while the response streams in
+var showAnalysisFlag = &cli.BoolFlag{
+	Name:  "show-analysis",
+	Usage: "Print the model's conversion analysis as it is generated",
+}
+
+// dumpFlag writes every prompt and raw response of a run to a directory
+var dumpFlag = &cli.StringFlag{
+	Name:  "dump",
+	Usage: "Write the full prompt and raw response of every model request to this directory",
+}
+
+// outputFlag selects how the result of a run is printed
+var outputFlag = &cli.StringFlag{
+	Name:  "output",
+	Usage: "Output format: text, or json for a single JSON document describing the run",
+	Value: "text",
+}
+
+// outputJSON reports whether the --output flag asks for JSON
+func outputJSON(cmd *cli.Command) (bool, error) {
+	switch format := cmd.String("output"); format {
+	case "", "text":
+		return false, nil
+	case "json":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown output format %q (expected text or json)", format)
+	}
+}
+
+// applyRunFlags copies the flags shared by the commands that translate to opts
+func applyRunFlags(cmd *cli.Command, opts *core.ExecuteOptions) error {
+	opts.Stats = cmd.Bool("stats")
+	opts.ShowAnalysis = cmd.Bool("show-analysis")
+	opts.DumpDir = cmd.String("dump")
+	if cmd.IsSet("max-cost") {
+		maxCost := cmd.Float("max-cost")
+		if maxCost < 0 {
+			return fmt.Errorf("--max-cost must not be negative")
+		}
+		opts.MaxCost = &maxCost
+	}
+	return nil
+}
+
+// applyFlags adds the profile and model selected on the command line to overrides
+func applyFlags(cmd *cli.Command, overrides *config.Overrides) {
+	overrides.Profile = cmd.String("profile")
+	overrides.Flags = config.ModelLayer(config.LayerFlag, "", cmd.String("model"), cmd.String("provider"))
+}
+
+// findProject returns the manifest of the project the current directory is
+// in, or nil when there is none
+func findProject() (*project.Manifest, error) {
+	manifest, err := project.Find(".")
+	if errors.Is(err, project.ErrNoManifest) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load project: %w", err)
+	}
+	return manifest, nil
+}
+
+// projectOptions returns execute options with the manifest's settings applied.
+// manifest may be nil.
+func projectOptions(manifest *project.Manifest) core.ExecuteOptions {
+	var opts core.ExecuteOptions
+	if manifest == nil {
+		return opts
+	}
+
+	opts.Project = manifest.Name
+	opts.Overrides.Project = config.ModelLayer(config.LayerProject, filepath.Join(manifest.Dir, project.ManifestName), manifest.Model, manifest.Provider)
+	opts.Run = core.RunOptions{
+		Interpreter: manifest.Python,
+		Timeout:     manifest.Timeout(),
+		CleanEnv:    manifest.Sandbox.CleanEnv,
+	}
+	return opts
+}
+
+// loadProgram loads the program starting at path, resolving use directives
+// against the project's source directories as well
+func loadProgram(path string, manifest *project.Manifest) (*core.Program, error) {
+	if manifest == nil {
+		return core.LoadProgram(path)
+	}
+	return core.LoadProgram(path, manifest.SourceDirs()...)
+}
+
+// entryFile returns the file given on the command line or, without one, the
+// project's entry file
+func entryFile(arg string, manifest *project.Manifest) (string, error) {
+	if arg != "" {
+		return arg, nil
+	}
+	if manifest == nil {
+		return "", fmt.Errorf("file path is required outside a project (run 'pseudo init' to create one)")
+	}
+	return manifest.EntryPath(), nil
+}
@@ -3,42 +3,319 @@ package commands
 import (
 	"context"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/urfave/cli/v3"
 	"github.com/username/pseudolang/internal/config"
+	"github.com/username/pseudolang/internal/core"
 )
 
 var ProviderCommand = &cli.Command{
 	Name:      "provider",
-	Usage:     "Set API token for a provider",
-	ArgsUsage: "<provider> <token>",
-	Action:    providerAction,
+	Usage:     "Set the API token source for a provider, or manage providers",
+	ArgsUsage: "<provider> [token]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "token-env",
+			Usage: "Read the token from this environment variable when it is needed",
+		},
+		&cli.StringFlag{
+			Name:  "token-command",
+			Usage: "Run this shell command when the token is needed and use its output",
+		},
+		&cli.BoolFlag{
+			Name:  "vault",
+			Usage: "Store the token in the encrypted vault (passphrase from $" + config.VaultPassphraseEnv + ")",
+		},
+		profileFlag,
+	},
+	Action: providerAction,
+	Commands: []*cli.Command{
+		{
+			Name:      "add",
+			Usage:     "Add a custom provider, such as a local OpenAI-compatible server",
+			ArgsUsage: "<name>",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "type",
+					Usage: "API the provider speaks",
+					Value: config.OpenAICompatible,
+				},
+				&cli.StringFlag{
+					Name:     "base-url",
+					Usage:    "API address, e.g. http://localhost:8000/v1",
+					Required: true,
+				},
+				&cli.StringSliceFlag{
+					Name:  "header",
+					Usage: "Extra request header as name=value (repeatable)",
+				},
+				&cli.StringFlag{
+					Name:  "token",
+					Usage: "API token, if the server needs one",
+				},
+			},
+			Action: providerAddAction,
+		},
+		{
+			Name:   "list",
+			Usage:  "List configured providers and their token sources",
+			Flags:  []cli.Flag{profileFlag},
+			Action: providerListAction,
+		},
+		{
+			Name:      "remove",
+			Usage:     "Delete the credentials for a provider",
+			ArgsUsage: "<provider>",
+			Flags:     []cli.Flag{profileFlag},
+			Action:    providerRemoveAction,
+		},
+		{
+			Name:      "verify",
+			Usage:     "Check that a provider accepts its token (default: the active provider)",
+			ArgsUsage: "[provider]",
+			Flags:     []cli.Flag{profileFlag},
+			Action:    providerVerifyAction,
+		},
+	},
 }
 
 func providerAction(ctx context.Context, cmd *cli.Command) error {
+	source, vault, err := tokenSource(cmd)
+	if err != nil {
+		return err
+	}
+
+	provider := cmd.Args().Get(0)
+
+	var profile string
+	err = config.Update(func(cfg *config.Config) error {
+		if _, err := cfg.ProviderType(provider); err != nil {
+			return err
+		}
+		profile = cfg.SelectedProfile(cmd.String("profile"))
+
+		if vault {
+			source.TokenVault = config.VaultEntry(profile, provider)
+			if err := config.VaultSet(source.TokenVault, cmd.Args().Get(1)); err != nil {
+				return fmt.Errorf("failed to store token in vault: %w", err)
+			}
+		}
+
+		if profile != "" {
+			return cfg.SetProfileTokenSource(profile, provider, source)
+		}
+		cfg.SetTokenSource(provider, source)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	where := ""
+	if profile != "" {
+		where = " in profile " + profile
+	}
+	fmt.Printf("Successfully saved API token for %s%s (source: %s)\n", provider, where, source.TokenSource())
+
+	return nil
+}
+
+// tokenSource builds the token source selected by the provider command's
+// arguments and flags. vault reports whether the token argument should be
+// stored in the vault.
+func tokenSource(cmd *cli.Command) (source config.ProviderConfig, vault bool, err error) {
+	sources := 0
+
+	if env := cmd.String("token-env"); env != "" {
+		source.TokenEnv = env
+		sources++
+	}
+	if command := cmd.String("token-command"); command != "" {
+		source.TokenCommand = command
+		sources++
+	}
+	if cmd.Bool("vault") {
+		vault = true
+		sources++
+	}
+	if sources > 1 {
+		return source, false, fmt.Errorf("use only one of --token-env, --token-command and --vault")
+	}
+
+	// The token itself is an argument unless it comes from elsewhere
+	if sources == 1 && !vault {
+		if cmd.Args().Len() != 1 {
+			return source, false, fmt.Errorf("expected exactly 1 argument: <provider>")
+		}
+		return source, false, nil
+	}
+
 	if cmd.Args().Len() != 2 {
-		return fmt.Errorf("expected exactly 2 arguments: <provider> <token>")
+		return source, false, fmt.Errorf("expected exactly 2 arguments: <provider> <token>")
+	}
+	if !vault {
+		source.Token = cmd.Args().Get(1)
+	}
+	return source, vault, nil
+}
+
+func providerAddAction(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("expected exactly 1 argument: <name>")
+	}
+	name := cmd.Args().Get(0)
+
+	p := config.ProviderConfig{
+		Type:    cmd.String("type"),
+		BaseURL: cmd.String("base-url"),
+		Token:   cmd.String("token"),
+	}
+	for _, header := range cmd.StringSlice("header") {
+		key, value, ok := strings.Cut(header, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid header %q: expected name=value", header)
+		}
+		if p.Headers == nil {
+			p.Headers = make(map[string]string)
+		}
+		p.Headers[key] = value
+	}
+
+	err := config.Update(func(cfg *config.Config) error {
+		return cfg.AddProvider(name, p)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Added provider %s (%s at %s)\n", name, p.Type, p.BaseURL)
+	return nil
+}
+
+func providerListAction(ctx context.Context, cmd *cli.Command) error {
+	var overrides config.Overrides
+	applyFlags(cmd, &overrides)
+
+	cfg, err := config.LoadResolved(overrides)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if len(cfg.Providers) == 0 {
+		fmt.Println("No providers configured. Use 'pseudo provider <provider> <token>' to add one")
+		return nil
+	}
+
+	providers := make([]string, 0, len(cfg.Providers))
+	for provider := range cfg.Providers {
+		providers = append(providers, provider)
 	}
+	sort.Strings(providers)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, provider := range providers {
+		p := cfg.Providers[provider]
+
+		marker := " "
+		if provider == cfg.ActiveProvider {
+			marker = "*"
+		}
+
+		// Only plain tokens are shown; other sources are not resolved here
+		token := p.TokenSource()
+		if p.Token != "" {
+			token = config.MaskToken(p.Token)
+		}
+
+		origin := cfg.Origin("providers." + provider + ".token")
+		for _, field := range []string{"token_env", "token_command", "token_vault"} {
+			if o, ok := cfg.Origins["providers."+provider+"."+field]; ok {
+				origin = o
+			}
+		}
 
+		fmt.Fprintf(w, "%s %s\t%s\t%s\n", marker, provider, token, origin)
+	}
+	return w.Flush()
+}
+
+func providerRemoveAction(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("expected exactly 1 argument: <provider>")
+	}
 	provider := cmd.Args().Get(0)
-	token := cmd.Args().Get(1)
 
-	if !config.IsValidProvider(provider) {
-		return fmt.Errorf("invalid provider: %s\nValid providers: openai, anthropic, groq, ollama, mistral, openrouter, azure-openai", provider)
+	var profile string
+	var removed config.ProviderConfig
+	err := config.Update(func(cfg *config.Config) error {
+		var err error
+		profile = cfg.SelectedProfile(cmd.String("profile"))
+		if profile != "" {
+			removed, err = cfg.RemoveProfileProvider(profile, provider)
+		} else {
+			removed, err = cfg.RemoveProvider(provider)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if removed.TokenVault != "" {
+		if err := config.VaultDelete(removed.TokenVault); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove %s from the vault: %v\n", removed.TokenVault, err)
+		}
+	}
+
+	where := ""
+	if profile != "" {
+		where = " from profile " + profile
+	}
+	fmt.Printf("Removed %s%s\n", provider, where)
+	return nil
+}
+
+func providerVerifyAction(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() > 1 {
+		return fmt.Errorf("expected at most 1 argument: [provider]")
 	}
 
-	cfg, err := config.Load()
+	var overrides config.Overrides
+	applyFlags(cmd, &overrides)
+
+	cfg, err := config.LoadResolved(overrides)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	cfg.SetProviderToken(provider, token)
+	provider := cmd.Args().Get(0)
+	if provider == "" {
+		provider = cfg.ActiveProvider
+	}
+	if provider == "" {
+		return fmt.Errorf("no active provider configured")
+	}
+
+	kind, err := cfg.ProviderType(provider)
+	if err != nil {
+		return err
+	}
 
-	if err := cfg.Save(); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
+	// A missing token is fine for local servers, but a configured source
+	// that fails is always an error
+	token, err := cfg.ResolveToken(provider)
+	if err != nil && (core.ProviderNeedsToken(kind) || cfg.Providers[provider].TokenSource() != "none") {
+		return err
 	}
 
-	fmt.Printf("Successfully saved API token for %s\n", provider)
+	if err := core.VerifyProvider(ctx, kind, token, cfg.Providers[provider], core.VerifyOptions{}); err != nil {
+		return err
+	}
 
+	fmt.Printf("%s: token accepted\n", provider)
 	return nil
 }
@@ -2,8 +2,6 @@ package commands
 
 import (
 	"context"
-	"fmt"
-	"os"
 
 	"github.com/urfave/cli/v3"
 	"github.com/username/pseudolang/internal/core"
@@ -12,28 +10,64 @@ import (
 var RunCommand = &cli.Command{
 	Name:      "run",
 	Usage:     "Run a pseudolang file",
-	ArgsUsage: "<file>",
-	Flags: []cli.Flag{
+	ArgsUsage: "[file]",
+	Flags: append([]cli.Flag{
 		&cli.BoolFlag{
 			Name:    "verbose",
 			Aliases: []string{"v"},
 			Usage:   "Print the generated Python code before execution",
 		},
-	},
+		&cli.BoolFlag{
+			Name:  "chunked",
+			Usage: "Translate large inputs in pieces split at top-level definitions",
+		},
+		&cli.IntFlag{
+			Name:  "chunk-tokens",
+			Usage: "Target size of one chunk in tokens when translating in pieces",
+			Value: core.DefaultChunkTokens,
+		},
+		&cli.BoolFlag{
+			Name:  "incremental",
+			Usage: "Only re-translate top-level definitions that changed since the last run",
+		},
+		statsFlag,
+		maxCostFlag,
+		showAnalysisFlag,
+		dumpFlag,
+		outputFlag,
+	}, modelFlags...),
 	Action: runAction,
 }
 
 func runAction(ctx context.Context, cmd *cli.Command) error {
-	filePath := cmd.Args().First()
-	if filePath == "" {
-		return fmt.Errorf("file path is required")
+	manifest, err := findProject()
+	if err != nil {
+		return err
 	}
 
-	content, err := os.ReadFile(filePath)
+	filePath, err := entryFile(cmd.Args().First(), manifest)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return err
+	}
+
+	program, err := loadProgram(filePath, manifest)
+	if err != nil {
+		return err
+	}
+
+	opts := projectOptions(manifest)
+	applyFlags(cmd, &opts.Overrides)
+	if err := applyRunFlags(cmd, &opts); err != nil {
+		return err
+	}
+	if opts.JSON, err = outputJSON(cmd); err != nil {
+		return err
 	}
+	opts.Verbose = cmd.Bool("verbose")
+	opts.Chunked = cmd.Bool("chunked")
+	opts.ChunkTokens = cmd.Int("chunk-tokens")
+	opts.Incremental = cmd.Bool("incremental")
+	opts.Source = filePath
 
-	verbose := cmd.Bool("verbose")
-	return core.ExecuteWithLLM(ctx, string(content), verbose)
+	return core.ExecuteProgram(ctx, program, opts)
 }
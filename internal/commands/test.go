@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"github.com/username/pseudolang/internal/core"
+	"github.com/username/pseudolang/internal/project"
+)
+
+// expectedOutputExt is the extension of the file holding a test's expected stdout
+const expectedOutputExt = ".expected"
+
+var TestCommand = &cli.Command{
+	Name:      "test",
+	Usage:     "Run the project's pseudolang test programs",
+	ArgsUsage: "[files...]",
+	Description: "Each test program passes when it exits successfully and, if a file with the " +
+		"same name and a " + expectedOutputExt + " extension exists, its output matches that file.",
+	Flags: append([]cli.Flag{
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "Print the output of failing tests",
+		},
+		statsFlag,
+		maxCostFlag,
+		showAnalysisFlag,
+		dumpFlag,
+	}, modelFlags...),
+	Action: testAction,
+}
+
+func testAction(ctx context.Context, cmd *cli.Command) error {
+	manifest, err := findProject()
+	if err != nil {
+		return err
+	}
+
+	files := cmd.Args().Slice()
+	if len(files) == 0 {
+		if manifest == nil {
+			return fmt.Errorf("test files are required outside a project (run 'pseudo init' to create one)")
+		}
+		files, err = manifest.TestFiles()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(files) == 0 {
+		return fmt.Errorf("no test files found")
+	}
+
+	opts := projectOptions(manifest)
+	applyFlags(cmd, &opts.Overrides)
+	if err := applyRunFlags(cmd, &opts); err != nil {
+		return err
+	}
+
+	failed := 0
+	for i, file := range files {
+		output, err := runTest(ctx, manifest, file, opts)
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %s: %v\n", file, err)
+			if cmd.Bool("verbose") && output != "" {
+				fmt.Println(output)
+			}
+
+			// Once the daily or monthly budget is spent every later test
+			// would be refused too
+			var budgetErr *core.BudgetError
+			if errors.As(err, &budgetErr) && budgetErr.Budget != core.BudgetPerRun {
+				return fmt.Errorf("stopped after %d of %d tests: %w", i+1, len(files), err)
+			}
+			continue
+		}
+		fmt.Printf("PASS %s\n", file)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tests failed", failed, len(files))
+	}
+
+	fmt.Printf("All %d tests passed\n", len(files))
+	return nil
+}
+
+// runTest runs one test program and returns its combined output
+func runTest(ctx context.Context, manifest *project.Manifest, file string, opts core.ExecuteOptions) (string, error) {
+	program, err := loadProgram(file, manifest)
+	if err != nil {
+		return "", err
+	}
+
+	opts.Source = file
+
+	var stdout, stderr bytes.Buffer
+	opts.Run.Stdout = &stdout
+	opts.Run.Stderr = &stderr
+
+	if err := core.ExecuteProgram(ctx, program, opts); err != nil {
+		return stdout.String() + stderr.String(), err
+	}
+
+	expectedPath := strings.TrimSuffix(file, filepath.Ext(file)) + expectedOutputExt
+	expected, err := os.ReadFile(expectedPath)
+	if os.IsNotExist(err) {
+		return stdout.String(), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read expected output: %w", err)
+	}
+
+	if strings.TrimSpace(stdout.String()) != strings.TrimSpace(string(expected)) {
+		return stdout.String(), fmt.Errorf("output does not match %s", filepath.Base(expectedPath))
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	"github.com/username/pseudolang/internal/core"
+)
+
+var UsageCommand = &cli.Command{
+	Name:  "usage",
+	Usage: "Summarize the tokens used and their cost",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "by",
+			Usage: "Group usage by day, model or project",
+			Value: core.UsageByDay,
+		},
+		&cli.IntFlag{
+			Name:  "days",
+			Usage: "Number of days to include, counting today",
+			Value: 30,
+		},
+	},
+	Action: usageAction,
+}
+
+func usageAction(ctx context.Context, cmd *cli.Command) error {
+	days := cmd.Int("days")
+	if days < 1 {
+		return fmt.Errorf("--days must be at least 1")
+	}
+
+	ledger, err := core.DefaultLedger()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day()-int(days)+1, 0, 0, 0, 0, now.Location())
+	entries, err := ledger.Entries(since)
+	if err != nil {
+		return fmt.Errorf("failed to read usage: %w", err)
+	}
+
+	summary, err := core.SummarizeUsage(entries, cmd.String("by"))
+	if err != nil {
+		return err
+	}
+	if len(summary) == 0 {
+		fmt.Printf("No usage recorded since %s\n", since.Format(time.DateOnly))
+		return nil
+	}
+
+	var total core.UsageTotal
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tRUNS\tREQUESTS\tPROMPT TOKENS\tCOMPLETION TOKENS\tCOST\n", usageHeading(cmd.String("by")))
+	for _, row := range summary {
+		key := row.Key
+		if key == "" {
+			key = "(none)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", key, row.Runs, row.Requests, row.PromptTokens, row.CompletionTokens, formatUsageCost(row))
+
+		total.Runs += row.Runs
+		total.Requests += row.Requests
+		total.PromptTokens += row.PromptTokens
+		total.CompletionTokens += row.CompletionTokens
+		total.Cost += row.Cost
+		total.UnpricedRuns += row.UnpricedRuns
+	}
+	fmt.Fprintf(w, "TOTAL\t%d\t%d\t%d\t%d\t%s\n", total.Runs, total.Requests, total.PromptTokens, total.CompletionTokens, formatUsageCost(total))
+	return w.Flush()
+}
+
+func usageHeading(by string) string {
+	switch by {
+	case core.UsageByModel:
+		return "MODEL"
+	case core.UsageByProject:
+		return "PROJECT"
+	}
+	return "DAY"
+}
+
+// formatUsageCost formats the cost of a group of runs, noting runs whose
+// cost is unknown and so not included
+func formatUsageCost(total core.UsageTotal) string {
+	cost := core.FormatCost(total.Cost)
+	if total.UnpricedRuns > 0 {
+		cost += fmt.Sprintf(" (+%d unpriced)", total.UnpricedRuns)
+	}
+	return cost
+}
@@ -4,106 +4,319 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 )
 
+// DefaultMaxTokens is the output token budget used for models without their
+// own setting or a known output limit
+const DefaultMaxTokens = 10000
+
+// ProviderConfig holds the settings for one provider. The token comes from
+// exactly one source: Token itself, or one of the fields after it.
 type ProviderConfig struct {
-	Token string `json:"token"`
+	Token string `json:"token,omitempty"`
+	// TokenEnv names an environment variable that holds the token
+	TokenEnv string `json:"token_env,omitempty"`
+	// TokenCommand is run by the shell when the token is needed; its output
+	// is the token, as with git credential helpers or `pass`
+	TokenCommand string `json:"token_command,omitempty"`
+	// TokenVault names the entry in the passphrase-encrypted vault that
+	// holds the token
+	TokenVault string `json:"token_vault,omitempty"`
+
+	// Type is the kind of API a custom provider speaks, e.g.
+	// "openai-compatible". Built-in providers leave it empty.
+	Type string `json:"type,omitempty"`
+	// BaseURL replaces the provider's default API address, in the form the
+	// provider's own SDK expects, e.g. "http://localhost:8000/v1"
+	BaseURL string `json:"base_url,omitempty"`
+	// Headers are sent with every request to the provider
+	Headers map[string]string `json:"headers,omitempty"`
+	// Deployment and APIVersion address an Azure OpenAI deployment
+	Deployment string `json:"deployment,omitempty"`
+	APIVersion string `json:"api_version,omitempty"`
 }
 
-type Config struct {
-	ActiveProvider string                    `json:"active_provider,omitempty"`
-	ActiveModel    string                    `json:"active_model,omitempty"`
-	Providers      map[string]ProviderConfig `json:"providers"`
+// GenerationOptions tune how a model generates text. Unset options use the
+// provider's defaults.
+type GenerationOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	// SystemPrompt is sent as the system message with every request
+	SystemPrompt string `json:"system_prompt,omitempty"`
 }
 
-var validProviders = map[string]bool{
-	"openai":       true,
-	"anthropic":    true,
-	"groq":         true,
-	"ollama":       true,
-	"mistral":      true,
-	"openrouter":   true,
-	"azure-openai": true,
+// overlay returns o with the options set in other applied on top
+func (o GenerationOptions) overlay(other GenerationOptions) GenerationOptions {
+	if other.Temperature != nil {
+		o.Temperature = other.Temperature
+	}
+	if other.TopP != nil {
+		o.TopP = other.TopP
+	}
+	if other.Seed != nil {
+		o.Seed = other.Seed
+	}
+	if other.MaxTokens > 0 {
+		o.MaxTokens = other.MaxTokens
+	}
+	if other.SystemPrompt != "" {
+		o.SystemPrompt = other.SystemPrompt
+	}
+	return o
 }
 
-func IsValidProvider(provider string) bool {
-	return validProviders[provider]
+// RetryOptions control how requests that fail with a temporary error, such
+// as a rate limit or a server error, are retried. Unset options use the
+// defaults.
+type RetryOptions struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries *int `json:"max_retries,omitempty"`
+	// InitialDelay and MaxDelay bound the backoff between retries, e.g. "1s"
+	InitialDelay string `json:"initial_delay,omitempty"`
+	MaxDelay     string `json:"max_delay,omitempty"`
 }
 
-func configPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
-	}
+// BudgetOptions cap what runs may spend, in US dollars. Unset budgets are
+// not enforced.
+type BudgetOptions struct {
+	// PerRun caps the cost of a single run
+	PerRun *float64 `json:"per_run,omitempty"`
+	// Daily and Monthly cap the total cost of the runs in the current
+	// calendar day and month, in local time
+	Daily   *float64 `json:"daily,omitempty"`
+	Monthly *float64 `json:"monthly,omitempty"`
+	// AllowUnpriced lets models without a known price run while a budget is
+	// set, although their cost cannot be checked against it
+	AllowUnpriced *bool `json:"allow_unpriced,omitempty"`
+}
+
+// ModelSettings holds generation settings for a single model, and registry
+// information that extends or overrides the built-in registry
+type ModelSettings struct {
+	GenerationOptions
+	ModelInfo
+}
 
-	configDir := filepath.Join(home, ".config", "pseudolang")
-	return filepath.Join(configDir, "config.json"), nil
+type Config struct {
+	// SchemaVersion is the file format version; see migrate.go. It is not a
+	// settable key.
+	SchemaVersion  int                       `json:"schema_version" config:"-"`
+	ActiveProvider string                    `json:"active_provider,omitempty"`
+	ActiveModel    string                    `json:"active_model,omitempty"`
+	Providers      map[string]ProviderConfig `json:"providers"`
+	Models         map[string]ModelSettings  `json:"models,omitempty"`
+	// ModelRoutes maps model name prefixes to providers, adding to and
+	// overriding the built-in routing rules; see RouteModel
+	ModelRoutes map[string]string `json:"model_routes,omitempty"`
+	// Aliases map short names such as "fast" to model names, which may name
+	// their provider as in "ollama:llama3"
+	Aliases map[string]string `json:"aliases,omitempty"`
+	// Fallbacks are the models to try, in order, when the active model is
+	// unavailable. Each may be an alias or name its provider.
+	Fallbacks     []string           `json:"fallbacks,omitempty"`
+	Retry         RetryOptions       `json:"retry,omitzero"`
+	Budget        BudgetOptions      `json:"budget,omitzero"`
+	Generation    GenerationOptions  `json:"generation,omitzero"`
+	ActiveProfile string             `json:"active_profile,omitempty"`
+	Profiles      map[string]Profile `json:"profiles,omitempty"`
 }
 
+// Load reads the global config file. A missing file is an empty config; a
+// file that cannot be parsed is backed up and reported as a *CorruptError.
+// Files written by older versions are migrated and saved in the current
+// format, keeping a copy of the original.
 func Load() (*Config, error) {
 	path, err := configPath()
 	if err != nil {
 		return nil, err
 	}
 
+	cfg, file, err := load(path)
+	if err != nil || !file.outdated() {
+		return cfg, err
+	}
+
+	err = withLock(path, func() error {
+		cfg, err = loadForUpdate(path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// configFile is the on-disk state a config was loaded from
+type configFile struct {
+	data []byte
+	// version is the schema version of data before migration
+	version int
+}
+
+func (f configFile) outdated() bool {
+	return f.data != nil && f.version < SchemaVersion
+}
+
+func load(path string) (*Config, configFile, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return &Config{
-				Providers: make(map[string]ProviderConfig),
-			}, nil
+				SchemaVersion: SchemaVersion,
+				Providers:     make(map[string]ProviderConfig),
+			}, configFile{version: SchemaVersion}, nil
+		}
+		return nil, configFile{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	corrupt := func(err error) error {
+		backup, backupErr := backupCorrupt(path, data)
+		if backupErr != nil {
+			backup = ""
 		}
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return &CorruptError{Path: path, Backup: backup, Err: err}
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, configFile{}, corrupt(err)
+	}
+
+	version, upgraded, err := migrate(path, raw)
+	file := configFile{data: data, version: version}
+	if err != nil {
+		return nil, file, err
 	}
 
 	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	if err := json.Unmarshal(upgraded, &cfg); err != nil {
+		return nil, file, corrupt(err)
 	}
 
 	if cfg.Providers == nil {
 		cfg.Providers = make(map[string]ProviderConfig)
 	}
 
-	return &cfg, nil
+	return &cfg, file, nil
 }
 
+// loadForUpdate loads the config while the caller holds the config lock,
+// saving it in the current format if it had to be migrated
+func loadForUpdate(path string) (*Config, error) {
+	cfg, file, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.outdated() {
+		backup := fmt.Sprintf("%s.v%d.bak", path, file.version)
+		if err := writeFileAtomic(backup, file.data, 0600); err != nil {
+			return nil, fmt.Errorf("failed to back up config before migrating: %w", err)
+		}
+		if err := cfg.save(path); err != nil {
+			return nil, fmt.Errorf("failed to save migrated config: %w", err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Save writes the config to the global config file. The file is replaced
+// atomically while holding the config lock. Use Update to also hold the lock
+// while the config is read and modified.
 func (c *Config) Save() error {
 	path, err := configPath()
 	if err != nil {
 		return err
 	}
 
-	configDir := filepath.Dir(path)
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
-	}
+	return withLock(path, func() error {
+		return c.save(path)
+	})
+}
+
+func (c *Config) save(path string) error {
+	c.SchemaVersion = SchemaVersion
 
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0600); err != nil {
+	if err := writeFileAtomic(path, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
 	return nil
 }
 
+// Update loads the global config, applies fn and saves the result, holding
+// the config lock throughout so concurrent updates cannot overwrite each
+// other. Nothing is saved when fn returns an error.
+func Update(fn func(cfg *Config) error) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+
+	return withLock(path, func() error {
+		cfg, err := loadForUpdate(path)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		if err := fn(cfg); err != nil {
+			return err
+		}
+
+		if err := cfg.save(path); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetToken resolves the token for provider. It reports false when the
+// provider is not configured or its token source fails; use ResolveToken to
+// find out why.
 func (c *Config) GetToken(provider string) (string, bool) {
-	if providerCfg, ok := c.Providers[provider]; ok {
-		return providerCfg.Token, true
+	token, err := c.ResolveToken(provider)
+	if err != nil {
+		return "", false
 	}
-	return "", false
+	return token, true
 }
 
+// SetProviderToken stores token for provider in plain text
 func (c *Config) SetProviderToken(provider, token string) {
+	c.SetTokenSource(provider, ProviderConfig{Token: token})
+}
+
+// SetTokenSource makes source's token fields the token source for provider,
+// replacing any previous source
+func (c *Config) SetTokenSource(provider string, source ProviderConfig) {
 	if c.Providers == nil {
 		c.Providers = make(map[string]ProviderConfig)
 	}
-	c.Providers[provider] = ProviderConfig{Token: token}
+	c.Providers[provider] = c.Providers[provider].withTokenSource(source)
+}
+
+// RemoveProvider deletes the settings for provider and returns them. When
+// provider is active, no model is active afterwards.
+func (c *Config) RemoveProvider(provider string) (ProviderConfig, error) {
+	removed, ok := c.Providers[provider]
+	if !ok {
+		return ProviderConfig{}, fmt.Errorf("provider not configured: %s", provider)
+	}
+
+	delete(c.Providers, provider)
+	if c.ActiveProvider == provider {
+		c.ActiveProvider = ""
+		c.ActiveModel = ""
+	}
+	return removed, nil
 }
 
 func (c *Config) SetActiveProvider(provider string) error {
@@ -114,29 +327,94 @@ func (c *Config) SetActiveProvider(provider string) error {
 	return nil
 }
 
+// SetActiveModel makes model, which may be an alias or name its provider as
+// in "ollama:llama3", the active model
 func (c *Config) SetActiveModel(model string) error {
-	provider, err := DetermineProvider(model)
+	route, err := c.RouteModel(model)
 	if err != nil {
 		return err
 	}
 
-	if _, ok := c.Providers[provider]; !ok {
-		return fmt.Errorf("no token configured for provider: %s (required for model: %s)", provider, model)
+	if _, ok := c.Providers[route.Provider]; !ok {
+		return fmt.Errorf("no token configured for provider: %s (required for model: %s)", route.Provider, route.Model)
 	}
 
-	c.ActiveProvider = provider
-	c.ActiveModel = model
+	c.setActiveRoute(route)
 	return nil
 }
 
+// SetModelWithToken makes model the active model and stores token for its
+// provider
 func (c *Config) SetModelWithToken(model, token string) error {
-	provider, err := DetermineProvider(model)
+	route, err := c.RouteModel(model)
 	if err != nil {
 		return err
 	}
 
-	c.SetProviderToken(provider, token)
-	c.ActiveProvider = provider
-	c.ActiveModel = model
+	c.SetProviderToken(route.Provider, token)
+	c.setActiveRoute(route)
+	return nil
+}
+
+// setActiveRoute makes route's model active. An alias is stored as the
+// alias, without a provider, so that changing the alias takes effect.
+func (c *Config) setActiveRoute(route Route) {
+	if route.Alias != "" {
+		c.ActiveProvider = ""
+		c.ActiveModel = route.Alias
+		return
+	}
+	c.ActiveProvider = route.Provider
+	c.ActiveModel = route.Model
+}
+
+// GenerationFor returns the generation options for name, a model or an
+// alias: the general options with the model's own settings applied on top,
+// and for an alias the alias's settings on top of those
+func (c *Config) GenerationFor(name string) GenerationOptions {
+	options := c.Generation
+	if model := c.aliasTarget(name); model != name {
+		options = options.overlay(c.Models[model].GenerationOptions)
+	}
+	return options.overlay(c.Models[name].GenerationOptions)
+}
+
+// MaxTokens returns the output token budget for name, a model or an alias:
+// its max_tokens setting, else the model's output limit from the registry,
+// else DefaultMaxTokens
+func (c *Config) MaxTokens(name string) int {
+	if maxTokens := c.GenerationFor(name).MaxTokens; maxTokens > 0 {
+		return maxTokens
+	}
+	if info, ok := c.ModelInfo(c.aliasTarget(name)); ok && info.MaxOutputTokens > 0 {
+		return info.MaxOutputTokens
+	}
+	return DefaultMaxTokens
+}
+
+// aliasTarget returns the model that name is an alias for, without any
+// provider prefix, or name itself when it is not an alias
+func (c *Config) aliasTarget(name string) string {
+	target, ok := c.Aliases[name]
+	if !ok {
+		return name
+	}
+	if route, err := c.RouteModel(target); err == nil {
+		return route.Model
+	}
+	return target
+}
+
+// SetMaxTokens sets the output token budget for model
+func (c *Config) SetMaxTokens(model string, maxTokens int) error {
+	if maxTokens <= 0 {
+		return fmt.Errorf("max tokens must be positive, got %d", maxTokens)
+	}
+	if c.Models == nil {
+		c.Models = make(map[string]ModelSettings)
+	}
+	settings := c.Models[model]
+	settings.MaxTokens = maxTokens
+	c.Models[model] = settings
 	return nil
 }
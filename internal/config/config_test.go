@@ -378,3 +378,113 @@ func TestConfig_SetModelWithToken(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_MaxTokens(t *testing.T) {
+	cfg := &Config{}
+
+	if got := cfg.MaxTokens("gpt-4"); got != DefaultMaxTokens {
+		t.Errorf("Config.MaxTokens() without settings = %d, want %d", got, DefaultMaxTokens)
+	}
+
+	if err := cfg.SetMaxTokens("gpt-4", 2048); err != nil {
+		t.Fatalf("Config.SetMaxTokens() unexpected error = %v", err)
+	}
+	if got := cfg.MaxTokens("gpt-4"); got != 2048 {
+		t.Errorf("Config.MaxTokens() = %d, want 2048", got)
+	}
+	if got := cfg.MaxTokens("claude-3-opus"); got != DefaultMaxTokens {
+		t.Errorf("Config.MaxTokens() for other model = %d, want %d", got, DefaultMaxTokens)
+	}
+
+	if err := cfg.SetMaxTokens("gpt-4", 0); err == nil {
+		t.Errorf("Config.SetMaxTokens() with 0 expected error but got none")
+	}
+}
+
+func TestConfig_RemoveProvider(t *testing.T) {
+	cfg := &Config{
+		ActiveProvider: "openai",
+		ActiveModel:    "gpt-4",
+		Providers: map[string]ProviderConfig{
+			"openai":    {Token: "sk-test123"},
+			"anthropic": {TokenVault: "anthropic"},
+		},
+	}
+
+	removed, err := cfg.RemoveProvider("anthropic")
+	if err != nil {
+		t.Fatalf("RemoveProvider() unexpected error = %v", err)
+	}
+	if removed.TokenVault != "anthropic" {
+		t.Errorf("RemoveProvider() returned %+v, want the removed settings", removed)
+	}
+	if cfg.ActiveModel != "gpt-4" {
+		t.Errorf("RemoveProvider() changed the active model when removing an inactive provider")
+	}
+
+	if _, err := cfg.RemoveProvider("openai"); err != nil {
+		t.Fatalf("RemoveProvider() unexpected error = %v", err)
+	}
+	if cfg.ActiveProvider != "" || cfg.ActiveModel != "" {
+		t.Errorf("RemoveProvider() active = %s/%s, want both cleared", cfg.ActiveProvider, cfg.ActiveModel)
+	}
+	if len(cfg.Providers) != 0 {
+		t.Errorf("RemoveProvider() providers = %v, want none left", cfg.Providers)
+	}
+
+	if _, err := cfg.RemoveProvider("openai"); err == nil {
+		t.Errorf("RemoveProvider() expected error for unconfigured provider but got none")
+	}
+}
+
+func TestConfig_Aliases(t *testing.T) {
+	temperature, aliasTemperature := 0.7, 0.2
+	cfg := &Config{
+		Providers: map[string]ProviderConfig{"openai": {Token: "sk-test"}},
+		Aliases:   map[string]string{"fast": "openai:gpt-4o-mini", "loop": "fast"},
+		Generation: GenerationOptions{
+			SystemPrompt: "Write idiomatic Python.",
+		},
+		Models: map[string]ModelSettings{
+			"gpt-4o-mini": {GenerationOptions: GenerationOptions{Temperature: &temperature, MaxTokens: 4000}},
+			"fast":        {GenerationOptions: GenerationOptions{Temperature: &aliasTemperature, SystemPrompt: "Be terse."}},
+		},
+	}
+
+	route, err := cfg.RouteModel("fast")
+	if err != nil {
+		t.Fatalf("RouteModel(fast) unexpected error = %v", err)
+	}
+	if route.Provider != "openai" || route.Model != "gpt-4o-mini" || route.Alias != "fast" {
+		t.Errorf("RouteModel(fast) = %+v, want openai/gpt-4o-mini via fast", route)
+	}
+	if _, err := cfg.RouteModel("loop"); err == nil {
+		t.Errorf("RouteModel(loop) expected error for an alias of an alias but got none")
+	}
+
+	options := cfg.GenerationFor("fast")
+	if *options.Temperature != 0.2 || options.MaxTokens != 4000 || options.SystemPrompt != "Be terse." {
+		t.Errorf("GenerationFor(fast) = %+v, want the alias's settings over the model's", options)
+	}
+	if got := cfg.GenerationFor("gpt-4o-mini").SystemPrompt; got != "Write idiomatic Python." {
+		t.Errorf("GenerationFor(gpt-4o-mini) system prompt = %q, want the general one", got)
+	}
+	if got := cfg.MaxTokens("fast"); got != 4000 {
+		t.Errorf("MaxTokens(fast) = %d, want the model's 4000", got)
+	}
+
+	if err := cfg.SetActiveModel("fast"); err != nil {
+		t.Fatalf("SetActiveModel(fast) unexpected error = %v", err)
+	}
+	if cfg.ActiveModel != "fast" || cfg.ActiveProvider != "" {
+		t.Errorf("SetActiveModel(fast) active = %q/%q, want the alias without a provider", cfg.ActiveProvider, cfg.ActiveModel)
+	}
+
+	resolved, err := Merge(Defaults(), Layer{Name: LayerGlobal, Values: cfg.Values()})
+	if err != nil {
+		t.Fatalf("Merge() unexpected error = %v", err)
+	}
+	if resolved.ActiveModel != "gpt-4o-mini" || resolved.ActiveProvider != "openai" || resolved.SettingsName() != "fast" {
+		t.Errorf("Merge() active = %s/%s via %q, want openai/gpt-4o-mini via fast", resolved.ActiveProvider, resolved.ActiveModel, resolved.ActiveAlias)
+	}
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CorruptError is returned by Load when the config file cannot be parsed.
+// A copy of the file is kept at Backup so that fixing or replacing it cannot
+// lose the original.
+type CorruptError struct {
+	Path   string
+	Backup string
+	Err    error
+}
+
+func (e *CorruptError) Error() string {
+	msg := fmt.Sprintf("config file %s is corrupt: %v", e.Path, e.Err)
+	if e.Backup != "" {
+		msg += fmt.Sprintf("\nA copy was saved to %s", e.Backup)
+	}
+	return msg + "\nFix it with `pseudo config edit`, or remove it to start over"
+}
+
+func (e *CorruptError) Unwrap() error {
+	return e.Err
+}
+
+// backupCorrupt copies the unparseable contents of the config file next to
+// it. The name is derived from the contents, so loading the same corrupt file
+// repeatedly keeps a single backup.
+func backupCorrupt(path string, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	backup := fmt.Sprintf("%s.corrupt-%s", path, hex.EncodeToString(sum[:4]))
+
+	if _, err := os.Stat(backup); err == nil {
+		return backup, nil
+	}
+	if err := writeFileAtomic(backup, data, 0600); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+// withLock runs fn while holding an exclusive lock on the lock file that
+// guards the config file at path
+func withLock(path string, fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file: %w", err)
+	}
+	defer func() {
+		_ = lock.Close()
+	}()
+
+	if err := lockFile(lock); err != nil {
+		return fmt.Errorf("failed to lock config file: %w", err)
+	}
+	defer func() {
+		_ = unlockFile(lock)
+	}()
+
+	return fn()
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and
+// renames it over path, so readers see either the old or the new contents
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		_ = os.Remove(tmpName)
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, path)
+}
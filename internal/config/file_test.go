@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUpdate_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("PSEUDO_CONFIG", path)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := Update(func(cfg *Config) error {
+				return cfg.CreateProfile(fmt.Sprintf("p%d", i))
+			})
+			if err != nil {
+				t.Errorf("Update() unexpected error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	if len(cfg.Profiles) != 10 {
+		t.Errorf("Update() kept %d of 10 concurrent changes", len(cfg.Profiles))
+	}
+
+	leftovers, _ := filepath.Glob(path + ".tmp-*")
+	if len(leftovers) != 0 {
+		t.Errorf("Update() left temporary files behind: %v", leftovers)
+	}
+}
+
+func TestUpdate_ErrorSavesNothing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("PSEUDO_CONFIG", path)
+
+	want := errors.New("rejected")
+	err := Update(func(cfg *Config) error {
+		cfg.ActiveModel = "gpt-4"
+		return want
+	})
+	if !errors.Is(err, want) {
+		t.Errorf("Update() error = %v, want %v", err, want)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Update() wrote the config file although fn failed")
+	}
+}
+
+func TestLoad_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("PSEUDO_CONFIG", path)
+
+	data := []byte(`{"active_model": "gpt-4"`)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load()
+	var corrupt *CorruptError
+	if !errors.As(err, &corrupt) {
+		t.Fatalf("Load() error = %v, want a *CorruptError", err)
+	}
+	if !strings.HasPrefix(corrupt.Backup, path+".corrupt-") {
+		t.Fatalf("Load() backup = %q, want it next to the config file", corrupt.Backup)
+	}
+	if backup, _ := os.ReadFile(corrupt.Backup); string(backup) != string(data) {
+		t.Errorf("Load() backup contents = %q, want %q", backup, data)
+	}
+
+	if _, err := Load(); err == nil {
+		t.Fatalf("Load() expected error for corrupt file but got none")
+	}
+	backups, _ := filepath.Glob(path + ".corrupt-*")
+	if len(backups) != 1 {
+		t.Errorf("Load() made %d backups of the same file, want 1", len(backups))
+	}
+
+	if err := Update(func(cfg *Config) error { return nil }); err == nil {
+		t.Errorf("Update() expected error for corrupt file but got none")
+	}
+	if current, _ := os.ReadFile(path); string(current) != string(data) {
+		t.Errorf("Update() overwrote the corrupt file")
+	}
+}
@@ -0,0 +1,393 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/username/pseudolang/internal/logging"
+)
+
+// Config values are addressed by key paths: the JSON field names joined
+// with dots, e.g. "active_model" or "providers.openai.token". Map keys are
+// part of the path and may themselves contain dots, as in
+// "models.gpt-3.5-turbo.max_tokens".
+
+// ValidateKey reports whether key addresses a value in the Config schema
+func ValidateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	if !pathValid(reflect.TypeOf(Config{}), strings.Split(key, ".")) {
+		return fmt.Errorf("unknown config key: %s", key)
+	}
+	return nil
+}
+
+// Get returns the value at key, or "" when it is not set
+func (c *Config) Get(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+
+	value := ""
+	err := access(reflect.ValueOf(c).Elem(), strings.Split(key, "."), false, func(leaf reflect.Value) error {
+		value = formatLeaf(leaf)
+		return nil
+	})
+	return value, err
+}
+
+// Set parses value according to the type of the field at key and stores it.
+// Settings under providers are only accepted for a built-in provider or a
+// custom one already configured, so that a misspelt name is not stored as a
+// new provider.
+func (c *Config) Set(key, value string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	if name, ok := providerEntry(key); ok && !slices.Contains(BuiltinProviders, name) {
+		if _, exists := c.Providers[name]; !exists {
+			return fmt.Errorf("unknown provider in %s: %s\nValid providers: %s, or add a custom provider with 'pseudo provider add'",
+				key, name, strings.Join(BuiltinProviders, ", "))
+		}
+	}
+	return c.set(key, value)
+}
+
+// set is Set without the check for unknown providers, for layers, which may
+// define custom providers of their own
+func (c *Config) set(key, value string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	if isProviderKey(key) && value != "" {
+		if _, err := c.ProviderType(value); err != nil {
+			return fmt.Errorf("invalid provider: %s", value)
+		}
+	}
+	if strings.HasSuffix(key, "_delay") && value != "" {
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid value for %s: expected a duration such as 2s", key)
+		}
+	}
+
+	return access(reflect.ValueOf(c).Elem(), strings.Split(key, "."), true, func(leaf reflect.Value) error {
+		if err := parseLeaf(leaf, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+		return nil
+	})
+}
+
+// providerEntry returns the provider whose settings key addresses, if it
+// is under providers
+func providerEntry(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, "providers.")
+	if !ok {
+		return "", false
+	}
+	name, _, _ := strings.Cut(rest, ".")
+	return name, true
+}
+
+// isProviderKey reports whether the value at key names a provider
+func isProviderKey(key string) bool {
+	return key == "active_provider" || strings.HasPrefix(key, "model_routes.") ||
+		(strings.HasPrefix(key, "models.") && strings.HasSuffix(key, ".provider"))
+}
+
+// Unset clears the value at key. Map entries left empty are removed.
+func (c *Config) Unset(key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	return access(reflect.ValueOf(c).Elem(), strings.Split(key, "."), false, func(leaf reflect.Value) error {
+		leaf.Set(reflect.Zero(leaf.Type()))
+		return nil
+	})
+}
+
+// Values returns every value that is set, keyed by key path
+func (c *Config) Values() map[string]string {
+	values := make(map[string]string)
+	collect(reflect.ValueOf(c).Elem(), "", values)
+	return values
+}
+
+// SortedKeys returns the keys of values in order
+func SortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonName returns the JSON name of a struct field, or "" when it is not
+// serialized under its own name or is not addressable as a key
+func jsonName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" || field.Tag.Get("config") == "-" {
+		return ""
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	return name
+}
+
+// findField looks up the field called name in struct type t, searching
+// embedded structs that are serialized inline
+func findField(t reflect.Type, name string) ([]int, reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Tag.Get("json") == "" && field.Type.Kind() == reflect.Struct {
+			if index, fieldType, ok := findField(field.Type, name); ok {
+				return append([]int{i}, index...), fieldType, true
+			}
+			continue
+		}
+		if jsonName(field) == name {
+			return []int{i}, field.Type, true
+		}
+	}
+	return nil, nil, false
+}
+
+func isLeaf(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Int, reflect.Float64, reflect.Bool:
+		return true
+	case reflect.Ptr:
+		return isLeaf(t.Elem())
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// isStringMap reports whether t is a map whose values are leaves, such as
+// map[string]string
+func isStringMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String && isLeaf(t.Elem())
+}
+
+func pathValid(t reflect.Type, segments []string) bool {
+	switch {
+	case isLeaf(t):
+		return len(segments) == 0
+	case isStringMap(t):
+		return len(segments) > 0
+	case t.Kind() == reflect.Map:
+		_, ok := splitMapKey(t, segments)
+		return ok
+	case t.Kind() == reflect.Struct:
+		if len(segments) == 0 {
+			return false
+		}
+		_, fieldType, ok := findField(t, segments[0])
+		return ok && pathValid(fieldType, segments[1:])
+	}
+	return false
+}
+
+// splitMapKey finds how many leading segments form the map key, choosing the
+// shortest key for which the rest of the path is valid
+func splitMapKey(t reflect.Type, segments []string) (int, bool) {
+	for n := 1; n < len(segments); n++ {
+		if pathValid(t.Elem(), segments[n:]) {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// access walks v along segments and calls fn on the leaf. Missing map
+// entries are created when create is set and otherwise leave fn uncalled.
+func access(v reflect.Value, segments []string, create bool, fn func(leaf reflect.Value) error) error {
+	t := v.Type()
+
+	switch {
+	case isLeaf(t):
+		return fn(v)
+
+	case t.Kind() == reflect.Map:
+		n := len(segments)
+		if !isStringMap(t) {
+			n, _ = splitMapKey(t, segments)
+		}
+		key := reflect.ValueOf(strings.Join(segments[:n], "."))
+
+		if v.IsNil() {
+			if !create {
+				return nil
+			}
+			v.Set(reflect.MakeMap(t))
+		}
+
+		existing := v.MapIndex(key)
+		if !existing.IsValid() && !create {
+			return nil
+		}
+
+		elem := reflect.New(t.Elem()).Elem()
+		if existing.IsValid() {
+			elem.Set(existing)
+		}
+		if err := access(elem, segments[n:], create, fn); err != nil {
+			return err
+		}
+
+		if elem.IsZero() && !create {
+			v.SetMapIndex(key, reflect.Value{})
+		} else {
+			v.SetMapIndex(key, elem)
+		}
+		return nil
+
+	case t.Kind() == reflect.Struct:
+		index, _, _ := findField(t, segments[0])
+		return access(v.FieldByIndex(index), segments[1:], create, fn)
+	}
+
+	return fmt.Errorf("unsupported config value at %s", strings.Join(segments, "."))
+}
+
+func collect(v reflect.Value, prefix string, values map[string]string) {
+	t := v.Type()
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + "." + name
+	}
+
+	switch {
+	case isLeaf(t):
+		if !v.IsZero() {
+			values[prefix] = formatLeaf(v)
+		}
+
+	case t.Kind() == reflect.Map:
+		for _, key := range v.MapKeys() {
+			collect(v.MapIndex(key), join(key.String()), values)
+		}
+
+	case t.Kind() == reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if field.Anonymous && field.Tag.Get("json") == "" && field.Type.Kind() == reflect.Struct {
+				collect(v.Field(i), prefix, values)
+				continue
+			}
+			if name := jsonName(field); name != "" {
+				collect(v.Field(i), join(name), values)
+			}
+		}
+	}
+}
+
+func formatLeaf(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return ""
+		}
+		return formatLeaf(v.Elem())
+	case reflect.String:
+		return v.String()
+	case reflect.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = v.Index(i).String()
+		}
+		return strings.Join(items, ",")
+	}
+	return ""
+}
+
+func parseLeaf(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := parseLeaf(elem.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("expected an integer")
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("expected a number")
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected true or false")
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	}
+	return nil
+}
+
+// IsSecretKey reports whether the value at key must not be displayed in full:
+// a token, or a header whose name says it carries a credential, such as
+// Authorization or api-key
+func IsSecretKey(key string) bool {
+	if strings.HasSuffix(key, ".token") {
+		return true
+	}
+	_, header, ok := strings.Cut(key, ".headers.")
+	return ok && logging.IsSecretName(header)
+}
+
+// MaskToken hides all but the start and end of a secret
+func MaskToken(token string) string {
+	if len(token) <= 8 {
+		return strings.Repeat("*", len(token))
+	}
+	return token[:4] + strings.Repeat("*", 4) + token[len(token)-4:]
+}
+
+// DisplayValue returns the value at key as it should be shown to the user
+func DisplayValue(key, value string) string {
+	if IsSecretKey(key) {
+		return MaskToken(value)
+	}
+	return value
+}
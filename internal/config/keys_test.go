@@ -0,0 +1,154 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		wantErr bool
+	}{
+		{key: "active_model", wantErr: false},
+		{key: "active_provider", wantErr: false},
+		{key: "providers.openai.token", wantErr: false},
+		{key: "models.gpt-3.5-turbo.max_tokens", wantErr: false},
+		{key: "active_modle", wantErr: true},
+		{key: "providers.openai", wantErr: true},
+		{key: "providers.openai.tokn", wantErr: true},
+		{key: "models.gpt-4", wantErr: true},
+		{key: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			err := ValidateKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_SetGetUnset(t *testing.T) {
+	cfg := &Config{}
+
+	if err := cfg.Set("providers.openai.token", "sk-test"); err != nil {
+		t.Fatalf("Config.Set() unexpected error = %v", err)
+	}
+	if err := cfg.Set("models.gpt-3.5-turbo.max_tokens", "4096"); err != nil {
+		t.Fatalf("Config.Set() unexpected error = %v", err)
+	}
+
+	if got, _ := cfg.GetToken("openai"); got != "sk-test" {
+		t.Errorf("Config.Set() token = %q, want sk-test", got)
+	}
+	if got := cfg.Models["gpt-3.5-turbo"].MaxTokens; got != 4096 {
+		t.Errorf("Config.Set() max tokens = %d, want 4096", got)
+	}
+
+	if got, err := cfg.Get("models.gpt-3.5-turbo.max_tokens"); err != nil || got != "4096" {
+		t.Errorf("Config.Get() = %q, %v, want 4096", got, err)
+	}
+	if got, err := cfg.Get("providers.anthropic.token"); err != nil || got != "" {
+		t.Errorf("Config.Get() for unset key = %q, %v, want empty", got, err)
+	}
+
+	if err := cfg.Set("models.gpt-4.max_tokens", "many"); err == nil || !strings.Contains(err.Error(), "expected an integer") {
+		t.Errorf("Config.Set() with bad integer error = %v, want 'expected an integer'", err)
+	}
+
+	if err := cfg.Set("active_provider", "not-a-provider"); err == nil {
+		t.Errorf("Config.Set() with invalid provider expected error but got none")
+	}
+
+	// A misspelt provider must not be stored as a new one
+	if err := cfg.Set("providers.opnai.token", "abc"); err == nil || !strings.Contains(err.Error(), "pseudo provider add") {
+		t.Errorf("Config.Set() for an unknown provider error = %v, want it rejected with a pointer to 'pseudo provider add'", err)
+	}
+	if _, ok := cfg.Providers["opnai"]; ok {
+		t.Errorf("Config.Set() for an unknown provider created an entry")
+	}
+	cfg.Providers["local"] = ProviderConfig{Type: "openai-compatible", BaseURL: "http://localhost:8080/v1"}
+	if err := cfg.Set("providers.local.token", "abc"); err != nil {
+		t.Errorf("Config.Set() for a configured custom provider error = %v", err)
+	}
+
+	if err := cfg.Unset("providers.openai.token"); err != nil {
+		t.Fatalf("Config.Unset() unexpected error = %v", err)
+	}
+	if _, ok := cfg.Providers["openai"]; ok {
+		t.Errorf("Config.Unset() left an empty provider entry")
+	}
+}
+
+func TestConfig_Values(t *testing.T) {
+	cfg := &Config{
+		ActiveModel: "gpt-4",
+		Providers: map[string]ProviderConfig{
+			"openai": {Token: "sk-test"},
+		},
+		Models: map[string]ModelSettings{
+			"gpt-4": {GenerationOptions: GenerationOptions{MaxTokens: 2048}},
+		},
+	}
+
+	got := cfg.Values()
+	want := map[string]string{
+		"active_model":            "gpt-4",
+		"providers.openai.token":  "sk-test",
+		"models.gpt-4.max_tokens": "2048",
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("Config.Values() = %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("Config.Values()[%q] = %q, want %q", key, got[key], value)
+		}
+	}
+}
+
+func TestIsSecretKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "providers.openai.token", want: true},
+		{key: "providers.azure.headers.api-key", want: true},
+		{key: "providers.local.headers.Authorization", want: true},
+		{key: "profiles.work.providers.local.headers.X-Api-Key", want: true},
+		{key: "providers.openrouter.headers.X-Title", want: false},
+		{key: "providers.openai.base_url", want: false},
+		{key: "models.gpt-4.max_tokens", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsSecretKey(tt.key); got != tt.want {
+			t.Errorf("IsSecretKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+
+	if got := DisplayValue("providers.local.headers.Authorization", "Bearer sk-local-secret"); got != "Bear****cret" {
+		t.Errorf("DisplayValue(Authorization header) = %q, want it masked", got)
+	}
+}
+
+func TestMaskToken(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{token: "sk-ant-api03-abcdef", want: "sk-a****cdef"},
+		{token: "short", want: "*****"},
+		{token: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := MaskToken(tt.token); got != tt.want {
+			t.Errorf("MaskToken(%q) = %q, want %q", tt.token, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Layer names, from lowest to highest precedence
+const (
+	LayerDefault = "default"
+	LayerGlobal  = "global"
+	LayerProfile = "profile"
+	LayerProject = "project"
+	LayerEnv     = "env"
+	LayerFlag    = "flag"
+)
+
+// envKeys maps environment variables to the config key they set
+var envKeys = []struct {
+	name string
+	key  string
+}{
+	{"PSEUDO_MODEL", "active_model"},
+	{"PSEUDO_PROVIDER", "active_provider"},
+	{"OPENAI_API_KEY", "providers.openai.token"},
+	{"ANTHROPIC_API_KEY", "providers.anthropic.token"},
+	{"GROQ_API_KEY", "providers.groq.token"},
+	{"MISTRAL_API_KEY", "providers.mistral.token"},
+	{"OPENROUTER_API_KEY", "providers.openrouter.token"},
+	{"AZURE_OPENAI_API_KEY", "providers.azure-openai.token"},
+}
+
+// Layer is a partial configuration contributed by one source
+type Layer struct {
+	Name string
+	// Source describes where the values came from, e.g. a file path
+	Source string
+	Values map[string]string
+}
+
+// Origin records which layer an effective value came from
+type Origin struct {
+	Layer  string
+	Source string
+}
+
+func (o Origin) String() string {
+	if o.Source == "" {
+		return o.Layer
+	}
+	return fmt.Sprintf("%s (%s)", o.Layer, o.Source)
+}
+
+// Overrides are the layers that sit above the global config file
+type Overrides struct {
+	// Profile is the profile selected with --profile, if any
+	Profile string
+	// Project holds settings from the project manifest
+	Project Layer
+	// Flags holds settings from command-line flags
+	Flags Layer
+}
+
+// Resolved is the effective configuration after merging every layer
+type Resolved struct {
+	*Config
+	// Origins maps each set key to the layer that last set it
+	Origins map[string]Origin
+	// ActiveAlias is the alias the active model was selected by, if any
+	ActiveAlias string
+}
+
+// SettingsName returns the name the active model's generation settings are
+// kept under: its alias if it has one, else the model
+func (r *Resolved) SettingsName() string {
+	if r.ActiveAlias != "" {
+		return r.ActiveAlias
+	}
+	return r.ActiveModel
+}
+
+// Origin returns where the effective value of key came from
+func (r *Resolved) Origin(key string) Origin {
+	if origin, ok := r.Origins[key]; ok {
+		return origin
+	}
+	return Origin{Layer: LayerDefault}
+}
+
+// ModelLayer builds a layer that selects model and provider. Either may be
+// empty; see Merge for how a layer that only names a model picks its provider.
+func ModelLayer(name, source, model, provider string) Layer {
+	layer := Layer{Name: name, Source: source, Values: make(map[string]string)}
+	if model != "" {
+		layer.Values["active_model"] = model
+	}
+	if provider != "" {
+		layer.Values["active_provider"] = provider
+	}
+	return layer
+}
+
+// EnvLayer reads the layer contributed by environment variables
+func EnvLayer() Layer {
+	layer := Layer{Name: LayerEnv, Values: make(map[string]string)}
+	var names []string
+
+	for _, env := range envKeys {
+		if value := os.Getenv(env.name); value != "" {
+			layer.Values[env.key] = value
+			names = append(names, env.name)
+		}
+	}
+
+	layer.Source = strings.Join(names, ", ")
+	return layer
+}
+
+// Defaults returns the built-in configuration
+func Defaults() *Config {
+	return &Config{
+		Providers: make(map[string]ProviderConfig),
+	}
+}
+
+// LoadResolved merges the built-in defaults, the global config file, the
+// selected profile, the project layer, environment variables and
+// command-line flags, in that order
+func LoadResolved(overrides Overrides) (*Resolved, error) {
+	global, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	layers := []Layer{{Name: LayerGlobal, Source: path, Values: global.Values()}}
+
+	if name := global.SelectedProfile(overrides.Profile); name != "" {
+		profile, err := global.ProfileLayer(name)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, profile)
+	}
+
+	layers = append(layers, overrides.Project, EnvLayer(), overrides.Flags)
+
+	return Merge(Defaults(), layers...)
+}
+
+// Merge applies layers on top of base in order and records the origin of
+// every value. A layer that sets the model also selects its provider: the
+// one named in "provider:model", else the layer's own active_provider, else
+// the one chosen by the routing rules merged so far. A model nothing routes
+// is an error unless the layer also sets active_provider. Aliases are
+// replaced by their model.
+func Merge(base *Config, layers ...Layer) (*Resolved, error) {
+	resolved := &Resolved{Config: base, Origins: make(map[string]Origin)}
+
+	for _, layer := range layers {
+		// A layer that sets a provider's token source replaces the source
+		// from lower layers rather than competing with it
+		for _, provider := range tokenSourceProviders(layer.Values) {
+			resolved.SetTokenSource(provider, ProviderConfig{})
+			for _, field := range tokenSourceFields {
+				delete(resolved.Origins, "providers."+provider+"."+field)
+			}
+		}
+
+		// Provider settings go first so that a layer can select a custom
+		// provider it defines
+		keys := SortedKeys(layer.Values)
+		sort.SliceStable(keys, func(i, j int) bool {
+			return strings.HasPrefix(keys[i], "providers.") && !strings.HasPrefix(keys[j], "providers.")
+		})
+
+		for _, key := range keys {
+			if err := resolved.set(key, layer.Values[key]); err != nil {
+				return nil, fmt.Errorf("%s config: %w", layer.Name, err)
+			}
+			resolved.Origins[key] = Origin{Layer: layer.Name, Source: layer.Source}
+		}
+
+		if model, ok := layer.Values["active_model"]; ok {
+			resolved.ActiveAlias = ""
+			route, err := resolved.RouteModel(model)
+			if err != nil {
+				// The layer's own provider serves a model we know nothing
+				// about; without one the request would go to whichever
+				// provider a lower layer chose
+				if _, ok := layer.Values["active_provider"]; ok {
+					continue
+				}
+				return nil, fmt.Errorf("%s config: %w", layer.Name, err)
+			}
+
+			resolved.ActiveModel = route.Model
+			resolved.ActiveAlias = route.Alias
+			if _, ok := layer.Values["active_provider"]; !ok || route.Rule == nil {
+				resolved.ActiveProvider = route.Provider
+				resolved.Origins["active_provider"] = Origin{Layer: layer.Name, Source: layer.Source}
+			}
+		}
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	layers := []Layer{
+		{Name: LayerGlobal, Source: "config.json", Values: map[string]string{
+			"active_model":           "gpt-4",
+			"active_provider":        "openai",
+			"providers.openai.token": "sk-global",
+		}},
+		ModelLayer(LayerProject, "pseudo.json", "claude-3-opus", ""),
+		{Name: LayerEnv, Source: "OPENAI_API_KEY", Values: map[string]string{
+			"providers.openai.token": "sk-env",
+		}},
+		{Name: LayerFlag},
+	}
+
+	resolved, err := Merge(Defaults(), layers...)
+	if err != nil {
+		t.Fatalf("Merge() unexpected error = %v", err)
+	}
+
+	if resolved.ActiveModel != "claude-3-opus" || resolved.ActiveProvider != "anthropic" {
+		t.Errorf("Merge() active = %s/%s, want anthropic/claude-3-opus", resolved.ActiveProvider, resolved.ActiveModel)
+	}
+	if token, _ := resolved.GetToken("openai"); token != "sk-env" {
+		t.Errorf("Merge() openai token = %q, want sk-env", token)
+	}
+
+	wantOrigins := map[string]Origin{
+		"active_model":            {Layer: LayerProject, Source: "pseudo.json"},
+		"active_provider":         {Layer: LayerProject, Source: "pseudo.json"},
+		"providers.openai.token":  {Layer: LayerEnv, Source: "OPENAI_API_KEY"},
+		"models.gpt-4.max_tokens": {Layer: LayerDefault},
+	}
+	for key, want := range wantOrigins {
+		if got := resolved.Origin(key); got != want {
+			t.Errorf("Resolved.Origin(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestMergeRejectsUnknownKeys(t *testing.T) {
+	_, err := Merge(Defaults(), Layer{Name: LayerProject, Values: map[string]string{"modle": "gpt-4"}})
+	if err == nil {
+		t.Errorf("Merge() expected error for unknown key but got none")
+	}
+}
+
+func TestMergeUnknownModel(t *testing.T) {
+	global := Layer{Name: LayerGlobal, Values: map[string]string{"active_model": "gpt-4", "active_provider": "openai"}}
+
+	// Without a provider the model would silently go to the global one
+	_, err := Merge(Defaults(), global, ModelLayer(LayerFlag, "", "totally-unknown-model", ""))
+	if err == nil || !strings.Contains(err.Error(), "unable to determine provider for model: totally-unknown-model") {
+		t.Errorf("Merge() with an unknown model error = %v, want the routing error", err)
+	}
+
+	resolved, err := Merge(Defaults(), global, ModelLayer(LayerFlag, "", "totally-unknown-model", "ollama"))
+	if err != nil {
+		t.Fatalf("Merge() with an unknown model and a provider error = %v", err)
+	}
+	if resolved.ActiveModel != "totally-unknown-model" || resolved.ActiveProvider != "ollama" {
+		t.Errorf("Merge() active = %s/%s, want ollama/totally-unknown-model", resolved.ActiveProvider, resolved.ActiveModel)
+	}
+}
+
+func TestEnvLayer(t *testing.T) {
+	t.Setenv("PSEUDO_MODEL", "claude-3-opus")
+	t.Setenv("PSEUDO_PROVIDER", "")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant-env")
+	t.Setenv("OPENAI_API_KEY", "")
+
+	layer := EnvLayer()
+
+	want := map[string]string{
+		"active_model":              "claude-3-opus",
+		"providers.anthropic.token": "sk-ant-env",
+	}
+	if len(layer.Values) != len(want) {
+		t.Errorf("EnvLayer() values = %v, want %v", layer.Values, want)
+	}
+	for key, value := range want {
+		if layer.Values[key] != value {
+			t.Errorf("EnvLayer() values[%q] = %q, want %q", key, layer.Values[key], value)
+		}
+	}
+	if layer.Source != "PSEUDO_MODEL, ANTHROPIC_API_KEY" {
+		t.Errorf("EnvLayer() source = %q, want the variables that were set", layer.Source)
+	}
+}
+
+func TestLoadResolved(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("PSEUDO_CONFIG", "")
+	for _, env := range envKeys {
+		t.Setenv(env.name, "")
+	}
+
+	dir := filepath.Join(home, ".config", "pseudolang")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := `{"active_model": "gpt-4", "active_provider": "openai", "providers": {"openai": {"token": "sk-file"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := LoadResolved(Overrides{
+		Flags: ModelLayer(LayerFlag, "", "gpt-4o", ""),
+	})
+	if err != nil {
+		t.Fatalf("LoadResolved() unexpected error = %v", err)
+	}
+
+	if resolved.ActiveModel != "gpt-4o" {
+		t.Errorf("LoadResolved() active model = %q, want gpt-4o", resolved.ActiveModel)
+	}
+	if got := resolved.Origin("providers.openai.token").Layer; got != LayerGlobal {
+		t.Errorf("LoadResolved() token origin = %q, want %q", got, LayerGlobal)
+	}
+}
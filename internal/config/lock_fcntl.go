@@ -0,0 +1,25 @@
+//go:build solaris || illumos || aix
+
+package config
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile takes an exclusive POSIX record lock on the whole of f, blocking
+// until it is free. These systems have no flock.
+func lockFile(f *os.File) error {
+	return fcntlLock(f, unix.F_WRLCK)
+}
+
+func unlockFile(f *os.File) error {
+	return fcntlLock(f, unix.F_UNLCK)
+}
+
+func fcntlLock(f *os.File, lockType int16) error {
+	lock := unix.Flock_t{Type: lockType, Whence: io.SeekStart}
+	return unix.FcntlFlock(f.Fd(), unix.F_SETLKW, &lock)
+}
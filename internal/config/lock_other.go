@@ -0,0 +1,15 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris || illumos || aix || windows)
+
+package config
+
+import "os"
+
+// lockFile is a no-op on platforms without file locking. Writes are still
+// atomic, but concurrent updates may overwrite each other.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
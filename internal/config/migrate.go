@@ -0,0 +1,109 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// SchemaVersion is the version of the config file format written by this
+// build. Files without a schema_version are version 0.
+const SchemaVersion = 2
+
+// migrations[n] upgrades a config file from version n to n+1. Migrations
+// work on the raw JSON object so they can read fields that no longer exist
+// in Config.
+var migrations = []func(raw map[string]json.RawMessage) error{
+	// 0 -> 1: files gain a schema_version; the layout is otherwise unchanged
+	func(raw map[string]json.RawMessage) error {
+		return nil
+	},
+	// 1 -> 2: token_vault names the vault entry instead of being a flag. The
+	// flag's tokens were stored under the provider's name, in profiles too.
+	func(raw map[string]json.RawMessage) error {
+		if err := migrateVaultFlags(raw); err != nil {
+			return err
+		}
+		return eachObject(raw, "profiles", func(_ string, profile map[string]json.RawMessage) error {
+			return migrateVaultFlags(profile)
+		})
+	},
+}
+
+// migrateVaultFlags turns "token_vault": true under the providers of parent
+// into the name of the vault entry, and drops "token_vault": false
+func migrateVaultFlags(parent map[string]json.RawMessage) error {
+	return eachObject(parent, "providers", func(name string, provider map[string]json.RawMessage) error {
+		var flag bool
+		if value, ok := provider["token_vault"]; !ok || json.Unmarshal(value, &flag) != nil {
+			return nil
+		}
+		if !flag {
+			delete(provider, "token_vault")
+			return nil
+		}
+		entry, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		provider["token_vault"] = entry
+		return nil
+	})
+}
+
+// eachObject calls fn with every entry of the object at parent[key], whose
+// values are objects, and stores the entries fn changed
+func eachObject(parent map[string]json.RawMessage, key string, fn func(name string, object map[string]json.RawMessage) error) error {
+	value, ok := parent[key]
+	if !ok {
+		return nil
+	}
+
+	var objects map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(value, &objects); err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	for name, object := range objects {
+		if err := fn(name, object); err != nil {
+			return err
+		}
+	}
+
+	updated, err := json.Marshal(objects)
+	if err != nil {
+		return err
+	}
+	parent[key] = updated
+	return nil
+}
+
+// migrate upgrades the parsed config file at path to SchemaVersion. It
+// returns the version the file was in and the upgraded contents.
+func migrate(path string, raw map[string]json.RawMessage) (int, []byte, error) {
+	version := 0
+	if value, ok := raw["schema_version"]; ok {
+		if err := json.Unmarshal(value, &version); err != nil {
+			return 0, nil, fmt.Errorf("invalid schema_version: %w", err)
+		}
+	}
+
+	if version < 0 {
+		return version, nil, fmt.Errorf("invalid schema_version: %d", version)
+	}
+	if version > SchemaVersion {
+		return version, nil, fmt.Errorf("config file %s has schema version %d, but this version of pseudo only supports up to %d; upgrade pseudo to use it", path, version, SchemaVersion)
+	}
+
+	for v := version; v < SchemaVersion; v++ {
+		if err := migrations[v](raw); err != nil {
+			return version, nil, fmt.Errorf("failed to migrate config from version %d to %d: %w", v, v+1, err)
+		}
+	}
+
+	raw["schema_version"] = json.RawMessage(strconv.Itoa(SchemaVersion))
+	upgraded, err := json.Marshal(raw)
+	if err != nil {
+		return version, nil, err
+	}
+	return version, upgraded, nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad_MigratesOldFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("PSEUDO_CONFIG", path)
+
+	data := `{"active_model": "gpt-4", "active_provider": "openai", "providers": {"openai": {"token": "sk-old"}}}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	if cfg.ActiveModel != "gpt-4" || cfg.Providers["openai"].Token != "sk-old" {
+		t.Errorf("Load() lost settings while migrating: %+v", cfg)
+	}
+
+	backup, err := os.ReadFile(path + ".v0.bak")
+	if err != nil {
+		t.Fatalf("Load() did not back up the original file: %v", err)
+	}
+	if string(backup) != data {
+		t.Errorf("Load() backup = %q, want the original contents", backup)
+	}
+
+	var saved struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	current, _ := os.ReadFile(path)
+	if err := json.Unmarshal(current, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.SchemaVersion != SchemaVersion {
+		t.Errorf("Load() saved schema_version = %d, want %d", saved.SchemaVersion, SchemaVersion)
+	}
+}
+
+func TestLoad_RefusesNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("PSEUDO_CONFIG", path)
+
+	data := `{"schema_version": 99, "active_model": "gpt-4"}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "schema version 99") {
+		t.Fatalf("Load() error = %v, want it to name the unsupported version", err)
+	}
+
+	if err := Update(func(cfg *Config) error { return nil }); err == nil {
+		t.Errorf("Update() expected error for newer schema version but got none")
+	}
+	if current, _ := os.ReadFile(path); string(current) != data {
+		t.Errorf("Update() modified a config from a newer version")
+	}
+}
+
+func TestSchemaVersionIsNotAKey(t *testing.T) {
+	if err := ValidateKey("schema_version"); err == nil {
+		t.Errorf("ValidateKey(schema_version) expected error but got none")
+	}
+
+	cfg := &Config{SchemaVersion: SchemaVersion}
+	if _, ok := cfg.Values()["schema_version"]; ok {
+		t.Errorf("Values() includes schema_version")
+	}
+}
+
+func TestLoad_MigratesVaultFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("PSEUDO_CONFIG", path)
+
+	// Version 1 marked vault tokens with a flag and stored them under the
+	// provider's name
+	data := `{"schema_version": 1, "providers": {"openai": {"token_vault": true}, "groq": {"token_vault": false, "token": "gsk"}},
+		"profiles": {"work": {"providers": {"anthropic": {"token_vault": true}}}}}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	if got := cfg.Providers["openai"].TokenVault; got != "openai" {
+		t.Errorf("Load() openai token_vault = %q, want openai", got)
+	}
+	if got := cfg.Providers["groq"]; got.TokenVault != "" || got.Token != "gsk" {
+		t.Errorf("Load() groq = %+v, want the false flag dropped and the token kept", got)
+	}
+	if got := cfg.Profiles["work"].Providers["anthropic"].TokenVault; got != "anthropic" {
+		t.Errorf("Load() work profile anthropic token_vault = %q, want anthropic", got)
+	}
+	if _, err := os.Stat(path + ".v1.bak"); err != nil {
+		t.Errorf("Load() did not back up the version 1 file: %v", err)
+	}
+}
@@ -2,29 +2,144 @@ package config
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
-var modelPatterns = map[string][]string{
-	"anthropic":    {"claude-"},
-	"openai":       {"gpt-", "o1-", "o3-"},
-	"groq":         {"llama-", "mixtral-", "gemma-"},
-	"ollama":       {"llama", "mistral", "codellama", "phi", "qwen"},
-	"mistral":      {"mistral-"},
-	"openrouter":   {"openrouter/"},
-	"azure-openai": {"azure/"},
+// Rule sources, as reported by RouteModel
+const (
+	RuleBuiltin  = "built-in"
+	RuleConfig   = "config"
+	RuleRegistry = "registry"
+)
+
+// RouteRule sends models whose name starts with Prefix to Provider
+type RouteRule struct {
+	Prefix   string
+	Provider string
+	// Source is RuleBuiltin, RuleConfig or RuleRegistry
+	Source string
+}
+
+func (r RouteRule) String() string {
+	return fmt.Sprintf("prefix %q -> %s (%s)", r.Prefix, r.Provider, r.Source)
+}
+
+// builtinRoutes are the default routing rules. Prefixes are lowercase.
+var builtinRoutes = []RouteRule{
+	{Prefix: "gpt-", Provider: "openai"},
+	{Prefix: "o1-", Provider: "openai"},
+	{Prefix: "o3-", Provider: "openai"},
+	{Prefix: "claude-", Provider: "anthropic"},
+	{Prefix: "llama-", Provider: "groq"},
+	{Prefix: "mixtral-", Provider: "groq"},
+	{Prefix: "gemma-", Provider: "groq"},
+	{Prefix: "llama", Provider: "ollama"},
+	{Prefix: "mistral", Provider: "ollama"},
+	{Prefix: "codellama", Provider: "ollama"},
+	{Prefix: "phi", Provider: "ollama"},
+	{Prefix: "qwen", Provider: "ollama"},
+	{Prefix: "mistral-", Provider: "mistral"},
+	{Prefix: "openrouter/", Provider: "openrouter"},
+	{Prefix: "azure/", Provider: "azure-openai"},
+}
+
+// Route is the provider chosen for a model name
+type Route struct {
+	Provider string
+	// Model is the name to send to the provider, without any "provider:"
+	// prefix
+	Model string
+	// Rule is the rule that matched, or nil when the provider was named
+	// explicitly
+	Rule *RouteRule
+	// Shadowed are the other rules that matched, in order of precedence
+	Shadowed []RouteRule
+	// Alias is the alias the model was selected by, if any
+	Alias string
+}
+
+// SettingsName returns the name the route's generation settings are kept
+// under: the alias if there is one, else the model
+func (r Route) SettingsName() string {
+	if r.Alias != "" {
+		return r.Alias
+	}
+	return r.Model
 }
 
+// DetermineProvider returns the provider for modelName using the built-in
+// rules only. Use Config.RouteModel to include rules from the config.
 func DetermineProvider(modelName string) (string, error) {
-	modelLower := strings.ToLower(modelName)
+	route, err := (&Config{}).RouteModel(modelName)
+	return route.Provider, err
+}
 
-	for provider, patterns := range modelPatterns {
-		for _, pattern := range patterns {
-			if strings.HasPrefix(modelLower, pattern) {
-				return provider, nil
+// RouteModel chooses the provider for name. An alias is replaced by the
+// model it stands for before routing. "provider:model" names the
+// provider explicitly when provider is built in or configured; otherwise the
+// rule with the longest matching prefix wins. A model registry entry with a
+// provider acts as a rule whose prefix is the whole name. On a tie a rule
+// from the config's model_routes beats a registry entry, which beats a
+// built-in rule. Matching ignores case.
+func (c *Config) RouteModel(name string) (Route, error) {
+	if target, ok := c.Aliases[name]; ok {
+		if _, ok := c.Aliases[target]; ok {
+			return Route{}, fmt.Errorf("alias %s refers to another alias, %s", name, target)
+		}
+		route, err := c.RouteModel(target)
+		if err != nil {
+			return Route{}, fmt.Errorf("alias %s: %w", name, err)
+		}
+		route.Alias = name
+		return route, nil
+	}
+
+	if provider, model, ok := strings.Cut(name, ":"); ok {
+		if _, err := c.ProviderType(provider); err == nil {
+			if model == "" {
+				return Route{}, fmt.Errorf("missing model name after %q", provider+":")
 			}
+			return Route{Provider: provider, Model: model}, nil
+		}
+	}
+
+	matches := c.matchingRules(strings.ToLower(name))
+	if len(matches) == 0 {
+		return Route{}, fmt.Errorf("unable to determine provider for model: %s", name)
+	}
+
+	return Route{
+		Provider: matches[0].Provider,
+		Model:    name,
+		Rule:     &matches[0],
+		Shadowed: matches[1:],
+	}, nil
+}
+
+// matchingRules returns the rules whose prefix matches name, best first
+func (c *Config) matchingRules(name string) []RouteRule {
+	var matches []RouteRule
+	for _, prefix := range SortedKeys(c.ModelRoutes) {
+		if strings.HasPrefix(name, strings.ToLower(prefix)) {
+			matches = append(matches, RouteRule{Prefix: prefix, Provider: c.ModelRoutes[prefix], Source: RuleConfig})
+		}
+	}
+	for _, model := range c.RegisteredModels() {
+		if info, _ := c.ModelInfo(model); info.Provider != "" && name == strings.ToLower(model) {
+			matches = append(matches, RouteRule{Prefix: model, Provider: info.Provider, Source: RuleRegistry})
+		}
+	}
+	for _, rule := range builtinRoutes {
+		if strings.HasPrefix(name, rule.Prefix) {
+			rule.Source = RuleBuiltin
+			matches = append(matches, rule)
 		}
 	}
 
-	return "", fmt.Errorf("unable to determine provider for model: %s", modelName)
+	// Config rules come first, so a stable sort breaks ties in their favour
+	sort.SliceStable(matches, func(i, j int) bool {
+		return len(matches[i].Prefix) > len(matches[j].Prefix)
+	})
+	return matches
 }
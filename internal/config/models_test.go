@@ -192,3 +192,110 @@ func TestDetermineProviderPrefixMatching(t *testing.T) {
 		})
 	}
 }
+
+func TestDetermineProviderLongestPrefix(t *testing.T) {
+	// "mistral-large" matches ollama's "mistral" and mistral's "mistral-"
+	for i := 0; i < 50; i++ {
+		if got, _ := DetermineProvider("mistral-large"); got != "mistral" {
+			t.Fatalf("DetermineProvider(mistral-large) = %q, want mistral", got)
+		}
+	}
+}
+
+func TestConfig_RouteModel(t *testing.T) {
+	cfg := &Config{
+		Providers: map[string]ProviderConfig{
+			"vllm": {Type: OpenAICompatible, BaseURL: "http://gpu:8000/v1"},
+		},
+		ModelRoutes: map[string]string{
+			"llama-":       "vllm",
+			"meta-llama/":  "vllm",
+			"Claude-Local": "ollama",
+		},
+	}
+
+	tests := []struct {
+		name         string
+		model        string
+		wantProvider string
+		wantModel    string
+		wantSource   string
+		wantErr      bool
+	}{
+		{name: "explicit built-in", model: "ollama:llama3:8b", wantProvider: "ollama", wantModel: "llama3:8b"},
+		{name: "explicit custom", model: "vllm:qwen-72b", wantProvider: "vllm", wantModel: "qwen-72b"},
+		{name: "colon in model name", model: "llama3:8b", wantProvider: "ollama", wantModel: "llama3:8b", wantSource: RuleBuiltin},
+		{name: "missing model", model: "openai:", wantErr: true},
+		{name: "config rule wins tie", model: "llama-3-70b", wantProvider: "vllm", wantModel: "llama-3-70b", wantSource: RuleConfig},
+		{name: "config rule", model: "meta-llama/Llama-3.1-8B", wantProvider: "vllm", wantModel: "meta-llama/Llama-3.1-8B", wantSource: RuleConfig},
+		{name: "config rule ignores case", model: "claude-local-7b", wantProvider: "ollama", wantModel: "claude-local-7b", wantSource: RuleConfig},
+		{name: "longer built-in beats shorter config", model: "claude-3-opus", wantProvider: "anthropic", wantModel: "claude-3-opus", wantSource: RuleBuiltin},
+		{name: "no match", model: "unknown-model", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route, err := cfg.RouteModel(tt.model)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("RouteModel(%q) expected error but got none", tt.model)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RouteModel(%q) unexpected error = %v", tt.model, err)
+			}
+
+			if route.Provider != tt.wantProvider || route.Model != tt.wantModel {
+				t.Errorf("RouteModel(%q) = %s/%s, want %s/%s", tt.model, route.Provider, route.Model, tt.wantProvider, tt.wantModel)
+			}
+			source := ""
+			if route.Rule != nil {
+				source = route.Rule.Source
+			}
+			if source != tt.wantSource {
+				t.Errorf("RouteModel(%q) rule source = %q, want %q", tt.model, source, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestConfig_RouteModelShadowed(t *testing.T) {
+	route, err := (&Config{}).RouteModel("mistral-large")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if route.Rule.Prefix != "mistral-" || len(route.Shadowed) != 1 || route.Shadowed[0].Provider != "ollama" {
+		t.Errorf("RouteModel(mistral-large) rule = %v, shadowed = %v, want mistral- shadowing ollama's mistral", route.Rule, route.Shadowed)
+	}
+}
+
+func TestMerge_ModelRouting(t *testing.T) {
+	resolved, err := Merge(Defaults(),
+		Layer{Name: LayerGlobal, Values: map[string]string{
+			"active_model":       "llama",
+			"active_provider":    "ollama",
+			"model_routes.qwen-": "groq",
+		}},
+		ModelLayer(LayerEnv, "PSEUDO_MODEL", "qwen-2.5-32b", ""),
+	)
+	if err != nil {
+		t.Fatalf("Merge() unexpected error = %v", err)
+	}
+	if resolved.ActiveProvider != "groq" {
+		t.Errorf("Merge() active provider = %q, want groq from the config rule", resolved.ActiveProvider)
+	}
+
+	resolved, err = Merge(Defaults(), ModelLayer(LayerFlag, "", "openrouter:anthropic/claude-3-opus", "ollama"))
+	if err != nil {
+		t.Fatalf("Merge() unexpected error = %v", err)
+	}
+	if resolved.ActiveProvider != "openrouter" || resolved.ActiveModel != "anthropic/claude-3-opus" {
+		t.Errorf("Merge() active = %s/%s, want openrouter/anthropic/claude-3-opus", resolved.ActiveProvider, resolved.ActiveModel)
+	}
+
+	if _, err := Merge(Defaults(), Layer{Name: LayerGlobal, Values: map[string]string{"model_routes.x-": "nope"}}); err == nil {
+		t.Errorf("Merge() expected error for a rule with an unknown provider but got none")
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// appName is the directory name used under the config, cache and state roots
+const appName = "pseudolang"
+
+// explicitPath is the config file chosen with --config, if any
+var explicitPath string
+
+// SetPath makes path the config file for the rest of the process, as if it
+// were given with --config. An empty path restores the default lookup.
+func SetPath(path string) {
+	explicitPath = path
+}
+
+// configPath returns the config file to use: the --config path, then
+// $PSEUDO_CONFIG, then config.json under $XDG_CONFIG_HOME or ~/.config
+func configPath() (string, error) {
+	if explicitPath != "" {
+		return explicitPath, nil
+	}
+	if env := os.Getenv("PSEUDO_CONFIG"); env != "" {
+		return env, nil
+	}
+
+	dir, err := xdgDir("XDG_CONFIG_HOME", ".config")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// Path returns the location of the global config file
+func Path() (string, error) {
+	return configPath()
+}
+
+// CacheDir returns the directory for cached translations: $XDG_CACHE_HOME or
+// the platform's user cache directory
+func CacheDir() (string, error) {
+	if env := os.Getenv("XDG_CACHE_HOME"); filepath.IsAbs(env) {
+		return filepath.Join(env, appName), nil
+	}
+
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get cache directory: %w", err)
+	}
+
+	return filepath.Join(cache, appName), nil
+}
+
+// StateDir returns the directory for data that should persist between runs
+// but is not configuration, such as run history: $XDG_STATE_HOME or
+// ~/.local/state
+func StateDir() (string, error) {
+	return xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+}
+
+// xdgDir returns the pseudolang directory under the base directory named by
+// env, falling back to fallback inside the home directory. Relative values
+// of env are ignored, as the XDG spec requires.
+func xdgDir(env, fallback string) (string, error) {
+	if base := os.Getenv(env); filepath.IsAbs(base) {
+		return filepath.Join(base, appName), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	return filepath.Join(home, fallback, appName), nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("PSEUDO_CONFIG", "")
+	defer SetPath("")
+
+	steps := []struct {
+		name  string
+		apply func()
+		want  string
+	}{
+		{
+			name:  "home directory",
+			apply: func() {},
+			want:  filepath.Join(home, ".config", "pseudolang", "config.json"),
+		},
+		{
+			name:  "relative XDG_CONFIG_HOME is ignored",
+			apply: func() { t.Setenv("XDG_CONFIG_HOME", "relative") },
+			want:  filepath.Join(home, ".config", "pseudolang", "config.json"),
+		},
+		{
+			name:  "XDG_CONFIG_HOME",
+			apply: func() { t.Setenv("XDG_CONFIG_HOME", "/xdg") },
+			want:  filepath.Join("/xdg", "pseudolang", "config.json"),
+		},
+		{
+			name:  "PSEUDO_CONFIG",
+			apply: func() { t.Setenv("PSEUDO_CONFIG", "/env/config.json") },
+			want:  "/env/config.json",
+		},
+		{
+			name:  "--config",
+			apply: func() { SetPath("/flag/config.json") },
+			want:  "/flag/config.json",
+		},
+	}
+
+	for _, step := range steps {
+		step.apply()
+		got, err := configPath()
+		if err != nil {
+			t.Fatalf("%s: configPath() unexpected error = %v", step.name, err)
+		}
+		if got != step.want {
+			t.Errorf("%s: configPath() = %q, want %q", step.name, got, step.want)
+		}
+	}
+}
+
+func TestStateDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	t.Setenv("XDG_STATE_HOME", "")
+	if got, _ := StateDir(); got != filepath.Join(home, ".local", "state", "pseudolang") {
+		t.Errorf("StateDir() = %q, want it under ~/.local/state", got)
+	}
+
+	t.Setenv("XDG_STATE_HOME", "/state")
+	if got, _ := StateDir(); got != filepath.Join("/state", "pseudolang") {
+		t.Errorf("StateDir() = %q, want it under $XDG_STATE_HOME", got)
+	}
+}
+
+func TestCacheDir(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/cache")
+	if got, _ := CacheDir(); got != filepath.Join("/cache", "pseudolang") {
+		t.Errorf("CacheDir() = %q, want it under $XDG_CACHE_HOME", got)
+	}
+}
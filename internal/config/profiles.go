@@ -0,0 +1,188 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+)
+
+var profileNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Profile is a named set of settings that overrides the base config when
+// selected. Its fields use the same keys as Config.
+type Profile struct {
+	ActiveProvider string                    `json:"active_provider,omitempty"`
+	ActiveModel    string                    `json:"active_model,omitempty"`
+	Providers      map[string]ProviderConfig `json:"providers,omitempty"`
+	Generation     GenerationOptions         `json:"generation,omitzero"`
+}
+
+// SelectedProfile returns the profile to use: the --profile flag, then
+// PSEUDO_PROFILE, then the config's active profile. "" means no profile.
+func (c *Config) SelectedProfile(flag string) string {
+	if flag != "" {
+		return flag
+	}
+	if env := os.Getenv("PSEUDO_PROFILE"); env != "" {
+		return env
+	}
+	return c.ActiveProfile
+}
+
+// ProfileLayer returns the values of the named profile as a config layer
+func (c *Config) ProfileLayer(name string) (Layer, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return Layer{}, fmt.Errorf("unknown profile: %s", name)
+	}
+
+	layer := Layer{Name: LayerProfile, Source: name, Values: make(map[string]string)}
+	collect(reflect.ValueOf(profile), "", layer.Values)
+	return layer, nil
+}
+
+// CreateProfile adds an empty profile called name
+func (c *Config) CreateProfile(name string) error {
+	if !profileNameRe.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, '-' and '_'", name)
+	}
+	if _, ok := c.Profiles[name]; ok {
+		return fmt.Errorf("profile already exists: %s", name)
+	}
+
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]Profile)
+	}
+	c.Profiles[name] = Profile{}
+	return nil
+}
+
+// UseProfile makes name the active profile. An empty name deactivates profiles.
+func (c *Config) UseProfile(name string) error {
+	if name != "" {
+		if _, ok := c.Profiles[name]; !ok {
+			return fmt.Errorf("unknown profile: %s", name)
+		}
+	}
+	c.ActiveProfile = name
+	return nil
+}
+
+// DeleteProfile removes the profile called name, deactivating it if needed.
+// It returns the vault entries of the profile's tokens that no remaining
+// provider uses, for the caller to delete from the vault.
+func (c *Config) DeleteProfile(name string) ([]string, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile: %s", name)
+	}
+
+	delete(c.Profiles, name)
+	if c.ActiveProfile == name {
+		c.ActiveProfile = ""
+	}
+
+	inUse := c.vaultEntries()
+	var entries []string
+	for _, provider := range profile.Providers {
+		if entry := provider.TokenVault; entry != "" && !inUse[entry] && !slices.Contains(entries, entry) {
+			entries = append(entries, entry)
+		}
+	}
+	slices.Sort(entries)
+	return entries, nil
+}
+
+// vaultEntries returns the vault entries that the providers of the base
+// config and of every profile take their tokens from
+func (c *Config) vaultEntries() map[string]bool {
+	entries := make(map[string]bool)
+	for _, provider := range c.Providers {
+		entries[provider.TokenVault] = true
+	}
+	for _, profile := range c.Profiles {
+		for _, provider := range profile.Providers {
+			entries[provider.TokenVault] = true
+		}
+	}
+	delete(entries, "")
+	return entries
+}
+
+// SetProfileModel makes model the active model of the named profile. The
+// provider's token is stored in the profile when given; otherwise it must be
+// configured in the profile or the base config.
+func (c *Config) SetProfileModel(name, model, token string) error {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile: %s", name)
+	}
+
+	route, err := c.RouteModel(model)
+	if err != nil {
+		return err
+	}
+	provider := route.Provider
+
+	if token != "" {
+		if profile.Providers == nil {
+			profile.Providers = make(map[string]ProviderConfig)
+		}
+		profile.Providers[provider] = profile.Providers[provider].withTokenSource(ProviderConfig{Token: token})
+	} else {
+		_, inProfile := profile.Providers[provider]
+		_, inBase := c.Providers[provider]
+		if !inProfile && !inBase {
+			return fmt.Errorf("no token configured for provider: %s (required for model: %s)", provider, route.Model)
+		}
+	}
+
+	profile.ActiveProvider = provider
+	profile.ActiveModel = route.Model
+	if route.Alias != "" {
+		profile.ActiveProvider = ""
+		profile.ActiveModel = route.Alias
+	}
+	c.Profiles[name] = profile
+	return nil
+}
+
+// SetProfileTokenSource makes source's token fields the token source for
+// provider in the named profile
+func (c *Config) SetProfileTokenSource(name, provider string, source ProviderConfig) error {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile: %s", name)
+	}
+
+	if profile.Providers == nil {
+		profile.Providers = make(map[string]ProviderConfig)
+	}
+	profile.Providers[provider] = profile.Providers[provider].withTokenSource(source)
+	c.Profiles[name] = profile
+	return nil
+}
+
+// RemoveProfileProvider deletes the settings for provider from the named
+// profile and returns them
+func (c *Config) RemoveProfileProvider(name, provider string) (ProviderConfig, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return ProviderConfig{}, fmt.Errorf("unknown profile: %s", name)
+	}
+
+	removed, ok := profile.Providers[provider]
+	if !ok {
+		return ProviderConfig{}, fmt.Errorf("provider not configured in profile %s: %s", name, provider)
+	}
+
+	delete(profile.Providers, provider)
+	if profile.ActiveProvider == provider {
+		profile.ActiveProvider = ""
+		profile.ActiveModel = ""
+	}
+	c.Profiles[name] = profile
+	return removed, nil
+}
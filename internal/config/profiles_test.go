@@ -0,0 +1,164 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestConfig_Profiles(t *testing.T) {
+	cfg := &Config{Providers: map[string]ProviderConfig{"openai": {Token: "sk-base"}}}
+
+	if err := cfg.CreateProfile("work"); err != nil {
+		t.Fatalf("CreateProfile() unexpected error = %v", err)
+	}
+	if err := cfg.CreateProfile("work"); err == nil {
+		t.Errorf("CreateProfile() expected error for duplicate profile but got none")
+	}
+	if err := cfg.CreateProfile("bad name"); err == nil {
+		t.Errorf("CreateProfile() expected error for invalid name but got none")
+	}
+
+	if err := cfg.UseProfile("missing"); err == nil {
+		t.Errorf("UseProfile() expected error for unknown profile but got none")
+	}
+	if err := cfg.UseProfile("work"); err != nil {
+		t.Fatalf("UseProfile() unexpected error = %v", err)
+	}
+	if cfg.ActiveProfile != "work" {
+		t.Errorf("UseProfile() active profile = %q, want work", cfg.ActiveProfile)
+	}
+
+	if _, err := cfg.DeleteProfile("work"); err != nil {
+		t.Fatalf("DeleteProfile() unexpected error = %v", err)
+	}
+	if cfg.ActiveProfile != "" {
+		t.Errorf("DeleteProfile() active profile = %q, want it cleared", cfg.ActiveProfile)
+	}
+	if _, err := cfg.DeleteProfile("work"); err == nil {
+		t.Errorf("DeleteProfile() expected error for unknown profile but got none")
+	}
+}
+
+func TestConfig_DeleteProfileVaultEntries(t *testing.T) {
+	cfg := &Config{
+		Providers: map[string]ProviderConfig{"openai": {TokenVault: "openai"}},
+		Profiles: map[string]Profile{
+			"work": {Providers: map[string]ProviderConfig{
+				"anthropic": {TokenVault: "work/anthropic"},
+				// Migrated from a flag, the entry is shared with the base config
+				"openai": {TokenVault: "openai"},
+				"groq":   {TokenEnv: "GROQ_API_KEY"},
+			}},
+		},
+	}
+
+	entries, err := cfg.DeleteProfile("work")
+	if err != nil {
+		t.Fatalf("DeleteProfile() unexpected error = %v", err)
+	}
+	if !slices.Equal(entries, []string{"work/anthropic"}) {
+		t.Errorf("DeleteProfile() vault entries = %v, want only the entry no other provider uses", entries)
+	}
+}
+
+func TestConfig_SetProfileModel(t *testing.T) {
+	cfg := &Config{Providers: map[string]ProviderConfig{"openai": {Token: "sk-base"}}}
+	if err := cfg.CreateProfile("cheap"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.SetProfileModel("cheap", "gpt-4o-mini", ""); err != nil {
+		t.Errorf("SetProfileModel() unexpected error with a base token = %v", err)
+	}
+	if err := cfg.SetProfileModel("cheap", "claude-3-haiku", ""); err == nil {
+		t.Errorf("SetProfileModel() expected error without a token but got none")
+	}
+	if err := cfg.SetProfileModel("cheap", "claude-3-haiku", "sk-ant"); err != nil {
+		t.Fatalf("SetProfileModel() unexpected error = %v", err)
+	}
+
+	profile := cfg.Profiles["cheap"]
+	if profile.ActiveModel != "claude-3-haiku" || profile.ActiveProvider != "anthropic" {
+		t.Errorf("SetProfileModel() active = %s/%s, want anthropic/claude-3-haiku", profile.ActiveProvider, profile.ActiveModel)
+	}
+	if profile.Providers["anthropic"].Token != "sk-ant" {
+		t.Errorf("SetProfileModel() did not store the token in the profile")
+	}
+	if _, ok := cfg.Providers["anthropic"]; ok {
+		t.Errorf("SetProfileModel() stored the token in the base config")
+	}
+}
+
+func TestConfig_SelectedProfile(t *testing.T) {
+	cfg := &Config{ActiveProfile: "active"}
+
+	t.Setenv("PSEUDO_PROFILE", "")
+	if got := cfg.SelectedProfile(""); got != "active" {
+		t.Errorf("SelectedProfile() = %q, want active", got)
+	}
+
+	t.Setenv("PSEUDO_PROFILE", "env")
+	if got := cfg.SelectedProfile(""); got != "env" {
+		t.Errorf("SelectedProfile() = %q, want env", got)
+	}
+	if got := cfg.SelectedProfile("flag"); got != "flag" {
+		t.Errorf("SelectedProfile() = %q, want flag", got)
+	}
+}
+
+func TestLoadResolvedWithProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("PSEUDO_CONFIG", "")
+	t.Setenv("PSEUDO_PROFILE", "")
+	for _, env := range envKeys {
+		t.Setenv(env.name, "")
+	}
+
+	dir := filepath.Join(home, ".config", "pseudolang")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := `{
+		"active_model": "gpt-4",
+		"active_provider": "openai",
+		"providers": {"openai": {"token": "sk-file"}},
+		"active_profile": "personal",
+		"profiles": {
+			"personal": {"providers": {"openai": {"token": "sk-personal"}}},
+			"final": {"active_model": "claude-3-opus", "generation": {"temperature": 0}}
+		}
+	}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := LoadResolved(Overrides{})
+	if err != nil {
+		t.Fatalf("LoadResolved() unexpected error = %v", err)
+	}
+	if token, _ := resolved.GetToken("openai"); token != "sk-personal" {
+		t.Errorf("LoadResolved() openai token = %q, want the active profile's token", token)
+	}
+	if got := resolved.Origin("providers.openai.token"); got != (Origin{Layer: LayerProfile, Source: "personal"}) {
+		t.Errorf("LoadResolved() token origin = %v, want profile (personal)", got)
+	}
+
+	resolved, err = LoadResolved(Overrides{Profile: "final"})
+	if err != nil {
+		t.Fatalf("LoadResolved() unexpected error = %v", err)
+	}
+	if resolved.ActiveModel != "claude-3-opus" || resolved.ActiveProvider != "anthropic" {
+		t.Errorf("LoadResolved() active = %s/%s, want anthropic/claude-3-opus", resolved.ActiveProvider, resolved.ActiveModel)
+	}
+	if temp := resolved.Generation.Temperature; temp == nil || *temp != 0 {
+		t.Errorf("LoadResolved() temperature = %v, want 0 from the profile", temp)
+	}
+
+	if _, err := LoadResolved(Overrides{Profile: "missing"}); err == nil {
+		t.Errorf("LoadResolved() expected error for unknown profile but got none")
+	}
+}
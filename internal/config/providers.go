@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// OpenAICompatible is the type of custom providers that speak the OpenAI
+// chat completions API, such as llama.cpp and vLLM servers
+const OpenAICompatible = "openai-compatible"
+
+// BuiltinProviders are the providers that need no configuration beyond a token
+var BuiltinProviders = []string{"openai", "anthropic", "groq", "ollama", "mistral", "openrouter", "azure-openai"}
+
+// providerTypes are the values allowed for ProviderConfig.Type
+var providerTypes = []string{OpenAICompatible}
+
+var providerNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// IsValidProvider reports whether provider is a built-in provider
+func IsValidProvider(provider string) bool {
+	for _, builtin := range BuiltinProviders {
+		if provider == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+// IsProviderType reports whether t is a valid type for a custom provider
+func IsProviderType(t string) bool {
+	for _, valid := range providerTypes {
+		if t == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// ProviderType returns the kind of API the named provider speaks: its own
+// name for built-in providers, or the configured type of a custom provider
+func (c *Config) ProviderType(name string) (string, error) {
+	p := c.Providers[name]
+	if p.Type != "" {
+		if !IsProviderType(p.Type) {
+			return "", fmt.Errorf("provider %s has unknown type %q (valid types: %s)", name, p.Type, strings.Join(providerTypes, ", "))
+		}
+		return p.Type, nil
+	}
+	if IsValidProvider(name) {
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown provider: %s\nValid providers: %s, or a custom provider added with 'pseudo provider add'", name, strings.Join(BuiltinProviders, ", "))
+}
+
+// AddProvider configures a custom provider called name that speaks the API
+// given by p.Type. An existing token source for name is kept unless p sets
+// one.
+func (c *Config) AddProvider(name string, p ProviderConfig) error {
+	if IsValidProvider(name) {
+		return fmt.Errorf("%s is a built-in provider; set its base_url or headers with 'pseudo config set' instead", name)
+	}
+	if !providerNameRe.MatchString(name) {
+		return fmt.Errorf("invalid provider name %q: use lowercase letters, digits, '-' and '_'", name)
+	}
+	if !IsProviderType(p.Type) {
+		return fmt.Errorf("invalid provider type %q (valid types: %s)", p.Type, strings.Join(providerTypes, ", "))
+	}
+	if p.BaseURL == "" {
+		return fmt.Errorf("a base URL is required for custom provider %s", name)
+	}
+
+	existing := c.Providers[name]
+	if p.Token == "" && p.TokenEnv == "" && p.TokenCommand == "" && p.TokenVault == "" {
+		p = p.withTokenSource(existing)
+	}
+
+	if c.Providers == nil {
+		c.Providers = make(map[string]ProviderConfig)
+	}
+	c.Providers[name] = p
+	return nil
+}
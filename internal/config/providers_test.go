@@ -0,0 +1,81 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestConfig_AddProvider(t *testing.T) {
+	cfg := &Config{Providers: map[string]ProviderConfig{"vllm": {TokenEnv: "VLLM_KEY"}}}
+
+	err := cfg.AddProvider("vllm", ProviderConfig{Type: OpenAICompatible, BaseURL: "http://gpu:8000/v1"})
+	if err != nil {
+		t.Fatalf("AddProvider() unexpected error = %v", err)
+	}
+	if got := cfg.Providers["vllm"]; got.BaseURL != "http://gpu:8000/v1" || got.TokenEnv != "VLLM_KEY" {
+		t.Errorf("AddProvider() = %+v, want the new address and the existing token source", got)
+	}
+
+	invalid := []struct {
+		name     string
+		provider ProviderConfig
+	}{
+		{"openai", ProviderConfig{Type: OpenAICompatible, BaseURL: "http://x"}},
+		{"Bad Name", ProviderConfig{Type: OpenAICompatible, BaseURL: "http://x"}},
+		{"local", ProviderConfig{Type: "soap", BaseURL: "http://x"}},
+		{"local", ProviderConfig{Type: OpenAICompatible}},
+	}
+	for _, tt := range invalid {
+		if err := cfg.AddProvider(tt.name, tt.provider); err == nil {
+			t.Errorf("AddProvider(%q, %+v) expected error but got none", tt.name, tt.provider)
+		}
+	}
+}
+
+func TestConfig_ProviderType(t *testing.T) {
+	cfg := &Config{Providers: map[string]ProviderConfig{
+		"vllm":   {Type: OpenAICompatible, BaseURL: "http://gpu:8000/v1"},
+		"broken": {Type: "soap"},
+	}}
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "anthropic", want: "anthropic"},
+		{name: "vllm", want: OpenAICompatible},
+		{name: "broken", wantErr: true},
+		{name: "unknown", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := cfg.ProviderType(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ProviderType(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ProviderType(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMerge_CustomProviderInSameLayer(t *testing.T) {
+	resolved, err := Merge(Defaults(), Layer{Name: LayerGlobal, Values: map[string]string{
+		"active_provider":                    "vllm",
+		"active_model":                       "llama-3-8b",
+		"providers.vllm.type":                OpenAICompatible,
+		"providers.vllm.base_url":            "http://gpu:8000/v1",
+		"providers.vllm.headers.X-Team":      "compilers",
+		"providers.azure-openai.api_version": "2024-06-01",
+	}})
+	if err != nil {
+		t.Fatalf("Merge() unexpected error = %v", err)
+	}
+
+	if resolved.ActiveProvider != "vllm" {
+		t.Errorf("Merge() active provider = %q, want vllm", resolved.ActiveProvider)
+	}
+	if got := resolved.Providers["vllm"].Headers["X-Team"]; got != "compilers" {
+		t.Errorf("Merge() vllm header = %q, want compilers", got)
+	}
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"slices"
+	"sort"
+)
+
+// Model capabilities recorded in the registry
+const (
+	// CapabilityStructuredOutput means the model can be constrained to a
+	// JSON schema
+	CapabilityStructuredOutput = "structured_output"
+	// CapabilitySeed means the model accepts a seed for reproducible output
+	CapabilitySeed = "seed"
+)
+
+// ModelInfo describes a model in the registry. Zero fields are unknown; a
+// nil Capabilities means the capabilities are unknown, while an empty one
+// means the model has none of them.
+type ModelInfo struct {
+	Provider        string `json:"provider,omitempty"`
+	ContextWindow   int    `json:"context_window,omitempty"`
+	MaxOutputTokens int    `json:"max_output_tokens,omitempty"`
+	// InputPrice and OutputPrice are in US dollars per million tokens
+	InputPrice   *float64 `json:"input_price,omitempty"`
+	OutputPrice  *float64 `json:"output_price,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// Supports reports whether the model has capability
+func (m ModelInfo) Supports(capability string) bool {
+	return slices.Contains(m.Capabilities, capability)
+}
+
+func (m ModelInfo) isZero() bool {
+	return m.Provider == "" && m.ContextWindow == 0 && m.MaxOutputTokens == 0 &&
+		m.InputPrice == nil && m.OutputPrice == nil && len(m.Capabilities) == 0
+}
+
+// overlay returns m with the known fields of other applied on top
+func (m ModelInfo) overlay(other ModelInfo) ModelInfo {
+	if other.Provider != "" {
+		m.Provider = other.Provider
+	}
+	if other.ContextWindow > 0 {
+		m.ContextWindow = other.ContextWindow
+	}
+	if other.MaxOutputTokens > 0 {
+		m.MaxOutputTokens = other.MaxOutputTokens
+	}
+	if other.InputPrice != nil {
+		m.InputPrice = other.InputPrice
+	}
+	if other.OutputPrice != nil {
+		m.OutputPrice = other.OutputPrice
+	}
+	if other.Capabilities != nil {
+		m.Capabilities = other.Capabilities
+	}
+	return m
+}
+
+func price(dollars float64) *float64 {
+	return &dollars
+}
+
+var (
+	noCapabilities     = []string{}
+	openaiCapabilities = []string{CapabilityStructuredOutput, CapabilitySeed}
+	ollamaCapabilities = []string{CapabilityStructuredOutput, CapabilitySeed}
+)
+
+// builtinModels is the model registry shipped with pseudo. Entries in the
+// config's models section extend and override it.
+var builtinModels = map[string]ModelInfo{
+	"claude-opus-4-1-20250805":   {Provider: "anthropic", ContextWindow: 200000, MaxOutputTokens: 32000, InputPrice: price(15), OutputPrice: price(75), Capabilities: noCapabilities},
+	"claude-sonnet-4-5-20250929": {Provider: "anthropic", ContextWindow: 200000, MaxOutputTokens: 64000, InputPrice: price(3), OutputPrice: price(15), Capabilities: noCapabilities},
+	"claude-haiku-4-5-20251001":  {Provider: "anthropic", ContextWindow: 200000, MaxOutputTokens: 64000, InputPrice: price(1), OutputPrice: price(5), Capabilities: noCapabilities},
+	"claude-3-5-haiku-20241022":  {Provider: "anthropic", ContextWindow: 200000, MaxOutputTokens: 8192, InputPrice: price(0.8), OutputPrice: price(4), Capabilities: noCapabilities},
+
+	"gpt-4.1":      {Provider: "openai", ContextWindow: 1047576, MaxOutputTokens: 32768, InputPrice: price(2), OutputPrice: price(8), Capabilities: openaiCapabilities},
+	"gpt-4.1-mini": {Provider: "openai", ContextWindow: 1047576, MaxOutputTokens: 32768, InputPrice: price(0.4), OutputPrice: price(1.6), Capabilities: openaiCapabilities},
+	"gpt-4o":       {Provider: "openai", ContextWindow: 128000, MaxOutputTokens: 16384, InputPrice: price(2.5), OutputPrice: price(10), Capabilities: openaiCapabilities},
+	"gpt-4o-mini":  {Provider: "openai", ContextWindow: 128000, MaxOutputTokens: 16384, InputPrice: price(0.15), OutputPrice: price(0.6), Capabilities: openaiCapabilities},
+	"o3-mini":      {Provider: "openai", ContextWindow: 200000, MaxOutputTokens: 100000, InputPrice: price(1.1), OutputPrice: price(4.4), Capabilities: []string{CapabilityStructuredOutput}},
+
+	"llama-3.3-70b-versatile": {Provider: "groq", ContextWindow: 131072, MaxOutputTokens: 32768, InputPrice: price(0.59), OutputPrice: price(0.79), Capabilities: []string{CapabilitySeed}},
+	"llama-3.1-8b-instant":    {Provider: "groq", ContextWindow: 131072, MaxOutputTokens: 8192, InputPrice: price(0.05), OutputPrice: price(0.08), Capabilities: []string{CapabilitySeed}},
+
+	"mistral-large-latest": {Provider: "mistral", ContextWindow: 131072, InputPrice: price(2), OutputPrice: price(6), Capabilities: []string{CapabilityStructuredOutput, CapabilitySeed}},
+	"mistral-small-latest": {Provider: "mistral", ContextWindow: 32000, InputPrice: price(0.2), OutputPrice: price(0.6), Capabilities: []string{CapabilityStructuredOutput, CapabilitySeed}},
+
+	"llama3.2":      {Provider: "ollama", ContextWindow: 131072, InputPrice: price(0), OutputPrice: price(0), Capabilities: ollamaCapabilities},
+	"qwen2.5-coder": {Provider: "ollama", ContextWindow: 32768, InputPrice: price(0), OutputPrice: price(0), Capabilities: ollamaCapabilities},
+}
+
+// ModelInfo returns the registry entry for model: the built-in entry with
+// the config's entry applied on top. It reports false when neither exists.
+func (c *Config) ModelInfo(model string) (ModelInfo, bool) {
+	info, builtin := builtinModels[model]
+	if settings, ok := c.Models[model]; ok && !settings.ModelInfo.isZero() {
+		return info.overlay(settings.ModelInfo), true
+	}
+	return info, builtin
+}
+
+// RegisteredModels returns the names of the models in the registry, sorted
+func (c *Config) RegisteredModels() []string {
+	var names []string
+	for name := range builtinModels {
+		names = append(names, name)
+	}
+	for name, settings := range c.Models {
+		if _, ok := builtinModels[name]; !ok && !settings.ModelInfo.isZero() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Cost returns the price in US dollars of a request to model with the given
+// token counts. It reports false when the model's prices are unknown.
+func (c *Config) Cost(model string, promptTokens, completionTokens int) (float64, bool) {
+	info, _ := c.ModelInfo(model)
+	if info.InputPrice == nil || info.OutputPrice == nil {
+		return 0, false
+	}
+	input := float64(promptTokens) * *info.InputPrice
+	output := float64(completionTokens) * *info.OutputPrice
+	return (input + output) / 1e6, true
+}
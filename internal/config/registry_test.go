@@ -0,0 +1,92 @@
+package config
+
+import "testing"
+
+func TestConfig_ModelInfo(t *testing.T) {
+	cfg := &Config{Models: map[string]ModelSettings{
+		"gpt-4o":   {ModelInfo: ModelInfo{InputPrice: price(2)}},
+		"my-coder": {ModelInfo: ModelInfo{Provider: "ollama", ContextWindow: 16000}},
+		"gpt-4":    {GenerationOptions: GenerationOptions{MaxTokens: 2048}},
+	}}
+
+	info, ok := cfg.ModelInfo("gpt-4o")
+	if !ok {
+		t.Fatalf("ModelInfo(gpt-4o) reported an unknown model")
+	}
+	if *info.InputPrice != 2 || *info.OutputPrice != 10 || info.Provider != "openai" {
+		t.Errorf("ModelInfo(gpt-4o) = %+v, want the built-in entry with the config's input price", info)
+	}
+
+	if info, ok := cfg.ModelInfo("my-coder"); !ok || info.ContextWindow != 16000 {
+		t.Errorf("ModelInfo(my-coder) = %+v, %v, want the config's entry", info, ok)
+	}
+	if _, ok := cfg.ModelInfo("gpt-4"); ok {
+		t.Errorf("ModelInfo(gpt-4) reported a model with only generation settings as registered")
+	}
+
+	models := cfg.RegisteredModels()
+	found := false
+	for _, model := range models {
+		if model == "gpt-4" {
+			t.Errorf("RegisteredModels() included gpt-4, which has no registry information")
+		}
+		found = found || model == "my-coder"
+	}
+	if !found {
+		t.Errorf("RegisteredModels() = %v, want it to include my-coder", models)
+	}
+}
+
+func TestConfig_MaxTokensFromRegistry(t *testing.T) {
+	cfg := &Config{Models: map[string]ModelSettings{
+		"gpt-4o-mini": {GenerationOptions: GenerationOptions{MaxTokens: 4000}},
+	}}
+
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"gpt-4o", 16384},
+		{"gpt-4o-mini", 4000},
+		{"unknown-model", DefaultMaxTokens},
+	}
+	for _, tt := range tests {
+		if got := cfg.MaxTokens(tt.model); got != tt.want {
+			t.Errorf("MaxTokens(%q) = %d, want %d", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestConfig_RouteModelRegistry(t *testing.T) {
+	cfg := &Config{Models: map[string]ModelSettings{
+		"claude-distill": {ModelInfo: ModelInfo{Provider: "ollama"}},
+	}}
+
+	route, err := cfg.RouteModel("claude-distill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route.Provider != "ollama" || route.Rule.Source != RuleRegistry {
+		t.Errorf("RouteModel(claude-distill) = %s via %v, want ollama via the registry", route.Provider, route.Rule)
+	}
+}
+
+func TestConfig_Cost(t *testing.T) {
+	cfg := &Config{Models: map[string]ModelSettings{
+		"my-coder": {ModelInfo: ModelInfo{Provider: "ollama", InputPrice: price(1)}},
+	}}
+
+	// gpt-4o costs $2.50 per million prompt tokens and $10 per million
+	// completion tokens
+	cost, ok := cfg.Cost("gpt-4o", 1000, 500)
+	if !ok || cost != 0.0075 {
+		t.Errorf("Cost(gpt-4o) = %v, %v, want 0.0075, true", cost, ok)
+	}
+
+	if _, ok := cfg.Cost("my-coder", 1000, 500); ok {
+		t.Errorf("Cost(my-coder) reported a cost without an output price")
+	}
+	if _, ok := cfg.Cost("unknown-model", 1000, 500); ok {
+		t.Errorf("Cost(unknown-model) reported a cost for an unregistered model")
+	}
+}
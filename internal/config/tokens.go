@@ -0,0 +1,141 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// tokenCommandTimeout bounds how long a token_command may run
+const tokenCommandTimeout = 30 * time.Second
+
+// tokenSourceFields are the ProviderConfig keys that select a token source
+var tokenSourceFields = []string{"token", "token_env", "token_command", "token_vault"}
+
+// withTokenSource returns p with its token source replaced by source's
+func (p ProviderConfig) withTokenSource(source ProviderConfig) ProviderConfig {
+	p.Token = source.Token
+	p.TokenEnv = source.TokenEnv
+	p.TokenCommand = source.TokenCommand
+	p.TokenVault = source.TokenVault
+	return p
+}
+
+// TokenSource describes where the provider's token comes from, for display
+func (p ProviderConfig) TokenSource() string {
+	switch {
+	case p.Token != "":
+		return "config"
+	case p.TokenEnv != "":
+		return "$" + p.TokenEnv
+	case p.TokenCommand != "":
+		return "command: " + p.TokenCommand
+	case p.TokenVault != "":
+		return "vault: " + p.TokenVault
+	}
+	return "none"
+}
+
+// ResolveToken returns the token for provider, reading it from its source.
+// Commands and the vault are only consulted when this is called.
+func (c *Config) ResolveToken(provider string) (string, error) {
+	p, ok := c.Providers[provider]
+	if !ok {
+		return "", fmt.Errorf("no API token configured for provider: %s", provider)
+	}
+
+	switch {
+	case p.Token != "":
+		return p.Token, nil
+
+	case p.TokenEnv != "":
+		token := os.Getenv(p.TokenEnv)
+		if token == "" {
+			return "", fmt.Errorf("token for %s is read from $%s, which is not set", provider, p.TokenEnv)
+		}
+		return token, nil
+
+	case p.TokenCommand != "":
+		token, err := runTokenCommand(p.TokenCommand)
+		if err != nil {
+			return "", fmt.Errorf("token command for %s failed: %w", provider, err)
+		}
+		return token, nil
+
+	case p.TokenVault != "":
+		token, err := VaultGet(p.TokenVault)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token for %s from the vault: %w", provider, err)
+		}
+		return token, nil
+	}
+
+	return "", fmt.Errorf("no API token configured for provider: %s", provider)
+}
+
+// runTokenCommand runs command with the system shell and returns the first
+// line of its output
+func runTokenCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	cmd.Stdin = os.Stdin
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+
+	token, _, _ := strings.Cut(string(out), "\n")
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("command printed no token")
+	}
+	return token, nil
+}
+
+// VaultEntry returns the name of the vault entry for provider's token in
+// the named profile, or in the base config when profile is ""
+func VaultEntry(profile, provider string) string {
+	if profile == "" {
+		return provider
+	}
+	return profile + "/" + provider
+}
+
+// tokenSourceProviders returns the providers whose token source is set by
+// values, a set of config keys
+func tokenSourceProviders(values map[string]string) []string {
+	seen := make(map[string]bool)
+	var providers []string
+	for key := range values {
+		rest, ok := strings.CutPrefix(key, "providers.")
+		if !ok {
+			continue
+		}
+		for _, field := range tokenSourceFields {
+			if provider, ok := strings.CutSuffix(rest, "."+field); ok && !seen[provider] {
+				seen[provider] = true
+				providers = append(providers, provider)
+			}
+		}
+	}
+	return providers
+}
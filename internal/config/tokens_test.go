@@ -0,0 +1,159 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfig_ResolveToken(t *testing.T) {
+	t.Setenv("PSEUDO_TEST_TOKEN", "sk-from-env")
+	t.Setenv("PSEUDO_TEST_UNSET", "")
+
+	tests := []struct {
+		name     string
+		provider ProviderConfig
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "plain token",
+			provider: ProviderConfig{Token: "sk-plain"},
+			want:     "sk-plain",
+		},
+		{
+			name:     "environment variable",
+			provider: ProviderConfig{TokenEnv: "PSEUDO_TEST_TOKEN"},
+			want:     "sk-from-env",
+		},
+		{
+			name:     "unset environment variable",
+			provider: ProviderConfig{TokenEnv: "PSEUDO_TEST_UNSET"},
+			wantErr:  "PSEUDO_TEST_UNSET",
+		},
+		{
+			name:     "command",
+			provider: ProviderConfig{TokenCommand: "echo sk-from-command; echo ignored"},
+			want:     "sk-from-command",
+		},
+		{
+			name:     "failing command",
+			provider: ProviderConfig{TokenCommand: "echo locked >&2; exit 1"},
+			wantErr:  "locked",
+		},
+		{
+			name:     "no source",
+			provider: ProviderConfig{},
+			wantErr:  "no API token configured",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Providers: map[string]ProviderConfig{"openai": tt.provider}}
+
+			got, err := cfg.ResolveToken("openai")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ResolveToken() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				if _, ok := cfg.GetToken("openai"); ok {
+					t.Errorf("GetToken() ok = true for a failing source")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveToken() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveToken() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_SetTokenSource(t *testing.T) {
+	cfg := &Config{Providers: map[string]ProviderConfig{"openai": {Token: "sk-plain"}}}
+
+	cfg.SetTokenSource("openai", ProviderConfig{TokenEnv: "OPENAI_KEY"})
+	if got := cfg.Providers["openai"]; !reflect.DeepEqual(got, ProviderConfig{TokenEnv: "OPENAI_KEY"}) {
+		t.Errorf("SetTokenSource() = %+v, want only the new source", got)
+	}
+}
+
+func TestMerge_TokenSourceReplacesLowerLayers(t *testing.T) {
+	resolved, err := Merge(Defaults(),
+		Layer{Name: LayerGlobal, Values: map[string]string{"providers.openai.token_command": "pass openai"}},
+		Layer{Name: LayerEnv, Values: map[string]string{"providers.openai.token": "sk-env"}},
+	)
+	if err != nil {
+		t.Fatalf("Merge() unexpected error = %v", err)
+	}
+
+	if got := resolved.Providers["openai"]; !reflect.DeepEqual(got, ProviderConfig{Token: "sk-env"}) {
+		t.Errorf("Merge() openai = %+v, want only the env token", got)
+	}
+	if _, ok := resolved.Origins["providers.openai.token_command"]; ok {
+		t.Errorf("Merge() kept the origin of the replaced token source")
+	}
+}
+
+func TestVault(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PSEUDO_CONFIG", filepath.Join(dir, "config.json"))
+	t.Setenv(VaultPassphraseEnv, "correct horse")
+
+	if err := VaultSet("openai", "sk-secret"); err != nil {
+		t.Fatalf("VaultSet() unexpected error = %v", err)
+	}
+	if err := VaultSet("anthropic", "sk-ant-secret"); err != nil {
+		t.Fatalf("VaultSet() unexpected error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "config.vault"))
+	if err != nil {
+		t.Fatalf("VaultSet() did not write the vault: %v", err)
+	}
+	if strings.Contains(string(data), "sk-secret") {
+		t.Errorf("VaultSet() wrote the token in plain text")
+	}
+
+	cfg := &Config{Providers: map[string]ProviderConfig{"openai": {TokenVault: "openai"}}}
+	if token, err := cfg.ResolveToken("openai"); err != nil || token != "sk-secret" {
+		t.Errorf("ResolveToken() = %q, %v, want sk-secret", token, err)
+	}
+
+	t.Setenv(VaultPassphraseEnv, "wrong")
+	if _, err := VaultGet("openai"); err == nil {
+		t.Errorf("VaultGet() expected error for wrong passphrase but got none")
+	}
+
+	t.Setenv(VaultPassphraseEnv, "correct horse")
+	if err := VaultDelete("openai"); err != nil {
+		t.Fatalf("VaultDelete() unexpected error = %v", err)
+	}
+	if _, err := VaultGet("openai"); err == nil {
+		t.Errorf("VaultGet() expected error for deleted token but got none")
+	}
+	if token, err := VaultGet("anthropic"); err != nil || token != "sk-ant-secret" {
+		t.Errorf("VaultGet() = %q, %v, want the other token kept", token, err)
+	}
+
+	// An iteration count far beyond what is written is refused, not derived
+	var file map[string]any
+	data, _ = os.ReadFile(filepath.Join(dir, "config.vault"))
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	file["iterations"] = 1 << 40
+	data, _ = json.Marshal(file)
+	if err := os.WriteFile(filepath.Join(dir, "config.vault"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VaultGet("anthropic"); err == nil || !strings.Contains(err.Error(), "iteration count") {
+		t.Errorf("VaultGet() error = %v, want the iteration count rejected", err)
+	}
+}
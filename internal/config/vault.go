@@ -0,0 +1,213 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VaultPassphraseEnv is the environment variable holding the vault passphrase
+const VaultPassphraseEnv = "PSEUDO_VAULT_PASSPHRASE"
+
+const (
+	vaultVersion    = 1
+	vaultIterations = 600000
+	vaultKeyLength  = 32
+	vaultSaltLength = 16
+	// vaultMaxIterations bounds the iteration count a vault file may ask
+	// for, so that a damaged or tampered file cannot stall every command
+	// that reads a token
+	vaultMaxIterations = 10 * vaultIterations
+)
+
+// vaultFile is the on-disk form of the vault: the tokens, keyed by entry
+// name (see VaultEntry) and encoded as JSON, encrypted with AES-256-GCM under a key derived from
+// the passphrase with PBKDF2-SHA256
+type vaultFile struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// VaultPath returns the location of the token vault, next to the config file
+func VaultPath() (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".vault", nil
+}
+
+// VaultGet returns the token stored in the vault under entry
+func VaultGet(entry string) (string, error) {
+	path, err := VaultPath()
+	if err != nil {
+		return "", err
+	}
+
+	tokens, err := readVault(path)
+	if err != nil {
+		return "", err
+	}
+
+	token, ok := tokens[entry]
+	if !ok {
+		return "", fmt.Errorf("no token for %s in %s", entry, path)
+	}
+	return token, nil
+}
+
+// VaultSet stores token in the vault under entry, creating the vault if needed
+func VaultSet(entry, token string) error {
+	return updateVault(func(tokens map[string]string) {
+		tokens[entry] = token
+	})
+}
+
+// VaultDelete removes entry from the vault, if present
+func VaultDelete(entry string) error {
+	path, err := VaultPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	return updateVault(func(tokens map[string]string) {
+		delete(tokens, entry)
+	})
+}
+
+func updateVault(fn func(tokens map[string]string)) error {
+	path, err := VaultPath()
+	if err != nil {
+		return err
+	}
+
+	return withLock(path, func() error {
+		tokens := make(map[string]string)
+		if _, err := os.Stat(path); err == nil {
+			if tokens, err = readVault(path); err != nil {
+				return err
+			}
+		}
+
+		fn(tokens)
+		return writeVault(path, tokens)
+	})
+}
+
+func vaultPassphrase() (string, error) {
+	passphrase := os.Getenv(VaultPassphraseEnv)
+	if passphrase == "" {
+		return "", fmt.Errorf("set %s to unlock the token vault", VaultPassphraseEnv)
+	}
+	return passphrase, nil
+}
+
+func vaultCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, vaultKeyLength)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func readVault(path string) (map[string]string, error) {
+	passphrase, err := vaultPassphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("vault %s does not exist", path)
+		}
+		return nil, fmt.Errorf("failed to read vault: %w", err)
+	}
+
+	var file vaultFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse vault %s: %w", path, err)
+	}
+	if file.Version != vaultVersion {
+		return nil, fmt.Errorf("unsupported vault version %d in %s", file.Version, path)
+	}
+	if file.Iterations < 1 || file.Iterations > vaultMaxIterations {
+		return nil, fmt.Errorf("invalid iteration count in vault %s", path)
+	}
+
+	aead, err := vaultCipher(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt vault %s: wrong passphrase or damaged file", path)
+	}
+
+	var tokens map[string]string
+	if err := json.Unmarshal(plaintext, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse vault contents: %w", err)
+	}
+	return tokens, nil
+}
+
+// writeVault encrypts tokens with a fresh salt and nonce and writes them to path
+func writeVault(path string, tokens map[string]string) error {
+	passphrase, err := vaultPassphrase()
+	if err != nil {
+		return err
+	}
+
+	file := vaultFile{
+		Version:    vaultVersion,
+		Iterations: vaultIterations,
+		Salt:       make([]byte, vaultSaltLength),
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+
+	aead, err := vaultCipher(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return err
+	}
+
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, nil)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	return nil
+}
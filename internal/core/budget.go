@@ -0,0 +1,210 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/username/pseudolang/internal/config"
+)
+
+// Names of the budgets a run is checked against
+const (
+	BudgetPerRun  = "per-run"
+	BudgetDaily   = "daily"
+	BudgetMonthly = "monthly"
+)
+
+// BudgetError is returned when a run would exceed, or has exceeded, one of
+// the spend budgets
+type BudgetError struct {
+	// Budget is the name of the budget, e.g. BudgetDaily
+	Budget string
+	// Limit is the budget in US dollars
+	Limit float64
+	// Spent is what earlier runs spent against the budget
+	Spent float64
+	// Cost is what this run is estimated to cost or has cost so far
+	Cost      float64
+	Estimated bool
+}
+
+func (e *BudgetError) Error() string {
+	var msg string
+	if e.Estimated {
+		msg = fmt.Sprintf("estimated cost of %s would exceed the %s budget of %s", FormatCost(e.Cost), e.Budget, FormatCost(e.Limit))
+	} else {
+		msg = fmt.Sprintf("stopped after spending %s, over the %s budget of %s", FormatCost(e.Cost), e.Budget, FormatCost(e.Limit))
+	}
+	if e.Spent > 0 {
+		msg += fmt.Sprintf(" (%s already spent)", FormatCost(e.Spent))
+	}
+
+	if e.Budget == BudgetPerRun {
+		return msg + "; raise it with --max-cost or 'pseudo config set budget.per_run <dollars>'"
+	}
+	return msg + fmt.Sprintf("; raise it with 'pseudo config set budget.%s <dollars>'", e.Budget)
+}
+
+// budget is one limit on spending and what was spent against it before the
+// current run
+type budget struct {
+	name  string
+	limit float64
+	spent float64
+}
+
+// budgets are the limits a run must stay within
+type budgets []budget
+
+// loadBudgets returns the budgets that apply to a run. maxCost, when set,
+// replaces the per-run budget. What earlier runs spent is read from ledger
+// only when a daily or monthly budget is set.
+func loadBudgets(options config.BudgetOptions, maxCost *float64, ledger *Ledger, now time.Time) (budgets, error) {
+	var b budgets
+
+	perRun := options.PerRun
+	if maxCost != nil {
+		perRun = maxCost
+	}
+	if perRun != nil {
+		b = append(b, budget{name: BudgetPerRun, limit: *perRun})
+	}
+
+	if options.Daily == nil && options.Monthly == nil {
+		return b, nil
+	}
+
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	entries, err := ledger.Entries(month)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage: %w", err)
+	}
+
+	var spentToday, spentThisMonth float64
+	for _, entry := range entries {
+		if entry.Cost == nil {
+			continue
+		}
+		spentThisMonth += *entry.Cost
+		if !entry.Time.Before(day) {
+			spentToday += *entry.Cost
+		}
+	}
+
+	if options.Daily != nil {
+		b = append(b, budget{name: BudgetDaily, limit: *options.Daily, spent: spentToday})
+	}
+	if options.Monthly != nil {
+		b = append(b, budget{name: BudgetMonthly, limit: *options.Monthly, spent: spentThisMonth})
+	}
+	return b, nil
+}
+
+// check returns a *BudgetError for the first budget that a run costing cost
+// would take over its limit
+func (b budgets) check(cost float64, estimated bool) error {
+	for _, budget := range b {
+		if budget.spent+cost > budget.limit {
+			return &BudgetError{
+				Budget:    budget.name,
+				Limit:     budget.limit,
+				Spent:     budget.spent,
+				Cost:      cost,
+				Estimated: estimated,
+			}
+		}
+	}
+	return nil
+}
+
+// analysisTokens is roughly how long the conversion analysis that precedes
+// the code in a response is
+const analysisTokens = 512
+
+// plannedRequest is a request a run is expected to make: its prompt, and
+// the pseudocode it translates, which sizes the response
+type plannedRequest struct {
+	prompt string
+	source string
+}
+
+// planRequests returns the requests translating input as opts ask will make.
+// Incremental runs are planned as if no unit were cached, with the Python of
+// the rest of the program, about as long as its pseudocode, as fixed context.
+func planRequests(input string, opts ExecuteOptions) []plannedRequest {
+	switch {
+	case opts.Incremental:
+		units := SplitTopLevel(input)
+		summary := InterfaceSummary(units)
+		requests := make([]plannedRequest, len(units))
+		for i, unit := range units {
+			requests[i] = plannedRequest{prompt: BuildIncrementalPrompt(unit.Text, summary, input), source: unit.Text}
+		}
+		return requests
+	case opts.Chunked:
+		units := SplitTopLevel(input)
+		summary := InterfaceSummary(units)
+		chunks := SplitIntoChunks(units, opts.chunkTokens(), CountTokens)
+		requests := make([]plannedRequest, len(chunks))
+		for i, chunk := range chunks {
+			requests[i] = plannedRequest{prompt: BuildChunkPrompt(chunk.Text(), summary, i+1, len(chunks)), source: chunk.Text()}
+		}
+		return requests
+	}
+	return []plannedRequest{{prompt: BuildPseudocodePrompt(input), source: input}}
+}
+
+// tokens estimates the prompt and completion tokens of r. Its response is
+// assumed to hold an analysis and code about twice as long as its
+// pseudocode. A response longer than maxTokens takes continuation requests,
+// each sending the prompt again with what was generated so far.
+func (r plannedRequest) tokens(maxTokens int) (prompt, completion int) {
+	promptTokens := CountTokens(r.prompt)
+	expected := analysisTokens + 2*CountTokens(r.source)
+	requests := min((expected+maxTokens-1)/maxTokens, 1+maxContinuations)
+
+	prompt = promptTokens
+	overhead := CountTokens(BuildContinuationPrompt("", ""))
+	for i := 1; i < requests; i++ {
+		prompt += promptTokens + overhead + i*maxTokens
+	}
+	return prompt, min(expected, requests*maxTokens)
+}
+
+// estimateCost estimates what making requests with model will cost before
+// any of them is sent. It reports false when the model's prices are unknown.
+func estimateCost(cfg *config.Resolved, model string, maxTokens int, requests []plannedRequest) (float64, bool) {
+	var promptTokens, completionTokens int
+	for _, request := range requests {
+		prompt, completion := request.tokens(maxTokens)
+		promptTokens += prompt
+		completionTokens += completion
+	}
+	return cfg.Cost(model, promptTokens, completionTokens)
+}
+
+// preflight refuses a run making requests whose estimated cost would exceed
+// a budget. Since any model of the chain may end up answering, the estimate
+// is taken at the most expensive one. A model without a known price is
+// refused too, unless budget.allow_unpriced is set.
+func (c *modelChain) preflight(cfg *config.Resolved, requests []plannedRequest) error {
+	if len(c.budgets) == 0 {
+		return nil
+	}
+
+	var highest float64
+	for _, m := range c.models {
+		cost, ok := estimateCost(cfg, m.model, cfg.MaxTokens(m.settings), requests)
+		if !ok {
+			if cfg.Budget.AllowUnpriced == nil || !*cfg.Budget.AllowUnpriced {
+				return fmt.Errorf("the price of %s is unknown, so its cost cannot be checked against the budget; set it with 'pseudo config set models.%s.input_price <dollars>' and output_price, or run it anyway with 'pseudo config set budget.allow_unpriced true'", m.model, m.model)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: the price of %s is unknown, so its cost cannot be checked against the budget\n", m.model)
+			continue
+		}
+		highest = max(highest, cost)
+	}
+	return c.budgets.check(highest, true)
+}
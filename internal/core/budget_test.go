@@ -0,0 +1,203 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/username/pseudolang/internal/config"
+)
+
+func dollars(d float64) *float64 {
+	return &d
+}
+
+func TestLoadBudgets(t *testing.T) {
+	ledger := NewLedger(filepath.Join(t.TempDir(), LedgerName))
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.Local)
+	err := ledger.Append([]LedgerEntry{
+		{Time: now.AddDate(0, -1, 0), Cost: dollars(5)},
+		{Time: now.AddDate(0, 0, -3), Cost: dollars(2)},
+		{Time: now.Add(-time.Hour), Cost: dollars(0.5)},
+		{Time: now.Add(-time.Hour)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := config.BudgetOptions{PerRun: dollars(1), Daily: dollars(1), Monthly: dollars(3)}
+	b, err := loadBudgets(options, nil, ledger, now)
+	if err != nil {
+		t.Fatalf("loadBudgets() error = %v", err)
+	}
+
+	want := budgets{
+		{name: BudgetPerRun, limit: 1},
+		{name: BudgetDaily, limit: 1, spent: 0.5},
+		{name: BudgetMonthly, limit: 3, spent: 2.5},
+	}
+	if len(b) != len(want) {
+		t.Fatalf("loadBudgets() = %+v, want %+v", b, want)
+	}
+	for i := range want {
+		if b[i] != want[i] {
+			t.Errorf("budget %d = %+v, want %+v", i, b[i], want[i])
+		}
+	}
+
+	if b, _ := loadBudgets(options, dollars(0.25), ledger, now); b[0].limit != 0.25 {
+		t.Errorf("per-run limit with --max-cost = %v, want 0.25", b[0].limit)
+	}
+	if b, _ := loadBudgets(config.BudgetOptions{}, nil, ledger, now); len(b) != 0 {
+		t.Errorf("loadBudgets() with no budgets = %+v, want none", b)
+	}
+}
+
+func TestBudgets_Check(t *testing.T) {
+	b := budgets{
+		{name: BudgetPerRun, limit: 1},
+		{name: BudgetDaily, limit: 1, spent: 0.75},
+	}
+
+	if err := b.check(0.25, true); err != nil {
+		t.Errorf("check(0.25) error = %v, want a run that exactly reaches a budget allowed", err)
+	}
+
+	err := b.check(0.5, true)
+	var budgetErr *BudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Budget != BudgetDaily {
+		t.Fatalf("check(0.5) error = %v, want the daily budget exceeded", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "estimated cost of $0.50") || !strings.Contains(msg, "budget.daily") {
+		t.Errorf("error = %q, want the estimate and how to raise the daily budget", msg)
+	}
+
+	if err := b.check(2, false); !errors.As(err, &budgetErr) || budgetErr.Budget != BudgetPerRun || !strings.Contains(err.Error(), "--max-cost") {
+		t.Errorf("check(2) error = %v, want the per-run budget exceeded", err)
+	}
+}
+
+func TestModelChain_StopsOverBudget(t *testing.T) {
+	var calls int
+	meter := &UsageMeter{cost: func(model string, prompt, completion int) (float64, bool) {
+		return 0.75, true
+	}}
+	model := fakeModel("primary", 0, 0, &calls)
+	generate := model.generate
+	model.generate = func(ctx context.Context, prompt string) (string, error) {
+		out, err := generate(ctx, prompt)
+		meter.record(model.provider, model.model, Usage{PromptTokens: 1, CompletionTokens: 1})
+		return out, err
+	}
+
+	chain := &modelChain{
+		models:  []*chainModel{model},
+		meter:   meter,
+		budgets: budgets{{name: BudgetPerRun, limit: 1}},
+	}
+
+	if _, err := chain.generate(context.Background(), "prompt"); err != nil {
+		t.Fatalf("first generate() error = %v, want it within the budget", err)
+	}
+	if _, err := chain.generate(context.Background(), "prompt"); err != nil {
+		t.Fatalf("second generate() error = %v, want it allowed while spending is under the budget", err)
+	}
+
+	var budgetErr *BudgetError
+	if _, err := chain.generate(context.Background(), "prompt"); !errors.As(err, &budgetErr) || budgetErr.Estimated {
+		t.Errorf("third generate() error = %v, want the run stopped over budget", err)
+	}
+	if calls != 2 {
+		t.Errorf("model called %d times, want 2", calls)
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	cfg := &config.Resolved{Config: &config.Config{}}
+	long := strings.Repeat("let x = x + 1\n", 200)
+
+	small, ok := estimateCost(cfg, "gpt-4o", 10000, planRequests("print 1", ExecuteOptions{}))
+	if !ok || small <= 0 {
+		t.Fatalf("estimateCost(gpt-4o) = %v, %v, want a positive cost", small, ok)
+	}
+
+	large, _ := estimateCost(cfg, "gpt-4o", 10000, planRequests(long, ExecuteOptions{}))
+	if large <= small {
+		t.Errorf("estimates: small %v, large %v; want longer inputs to cost more", small, large)
+	}
+
+	if _, ok := estimateCost(cfg, "unknown-model", 10000, planRequests("print 1", ExecuteOptions{})); ok {
+		t.Errorf("estimateCost(unknown-model) reported a cost")
+	}
+}
+
+func TestPlanRequests(t *testing.T) {
+	input := "function a()\n  return 1\n\nfunction b()\n  return 2\n\nprint a() + b()"
+
+	if got := planRequests(input, ExecuteOptions{}); len(got) != 1 {
+		t.Errorf("planRequests() planned %d requests, want 1", len(got))
+	}
+
+	chunked := planRequests(input, ExecuteOptions{Chunked: true, ChunkTokens: 1})
+	if len(chunked) != 3 || !strings.Contains(chunked[0].prompt, "function b()") {
+		t.Errorf("planRequests(chunked) = %d requests, want one per chunk carrying the interface summary", len(chunked))
+	}
+
+	incremental := planRequests(input, ExecuteOptions{Incremental: true})
+	if len(incremental) != 3 || !strings.Contains(incremental[0].prompt, "print a() + b()") {
+		t.Errorf("planRequests(incremental) = %d requests, want one per unit carrying the rest of the program", len(incremental))
+	}
+}
+
+func TestPlannedRequest_Tokens(t *testing.T) {
+	request := plannedRequest{prompt: BuildPseudocodePrompt("x"), source: strings.Repeat("let x = x + 1\n", 200)}
+
+	prompt, completion := request.tokens(100000)
+	truncatedPrompt, truncatedCompletion := request.tokens(500)
+	if truncatedPrompt <= 2*prompt {
+		t.Errorf("prompt tokens = %d with continuations, %d without; want the continuation requests counted", truncatedPrompt, prompt)
+	}
+	if truncatedCompletion != min(completion, (1+maxContinuations)*500) {
+		t.Errorf("completion tokens = %d, want up to %d across the continuations", truncatedCompletion, (1+maxContinuations)*500)
+	}
+}
+
+func TestModelChain_PreflightMostExpensiveModel(t *testing.T) {
+	cfg := &config.Resolved{Config: &config.Config{}}
+	requests := planRequests(strings.Repeat("let x = x + 1\n", 200), ExecuteOptions{})
+	cheap, _ := estimateCost(cfg, "gpt-4o-mini", cfg.MaxTokens("gpt-4o-mini"), requests)
+	expensive, _ := estimateCost(cfg, "gpt-4o", cfg.MaxTokens("gpt-4o"), requests)
+
+	chain := &modelChain{
+		models:  []*chainModel{{model: "gpt-4o-mini", settings: "gpt-4o-mini"}, {model: "gpt-4o", settings: "gpt-4o"}},
+		budgets: budgets{{name: BudgetPerRun, limit: (cheap + expensive) / 2}},
+	}
+	var budgetErr *BudgetError
+	if err := chain.preflight(cfg, requests); !errors.As(err, &budgetErr) || budgetErr.Cost != expensive {
+		t.Errorf("preflight() error = %v, want the run refused at the fallback's price", err)
+	}
+}
+
+func TestModelChain_PreflightUnpriced(t *testing.T) {
+	cfg := &config.Resolved{Config: &config.Config{}}
+	requests := planRequests("print 1", ExecuteOptions{})
+	models := []*chainModel{{model: "gpt-4o"}, {model: "unknown-model"}}
+	chain := &modelChain{models: models, budgets: budgets{{name: BudgetPerRun, limit: 1}}}
+
+	if err := chain.preflight(cfg, requests); err == nil || !strings.Contains(err.Error(), "unknown-model") {
+		t.Errorf("preflight() error = %v, want an unpriced model refused under a budget", err)
+	}
+
+	allow := true
+	cfg.Budget.AllowUnpriced = &allow
+	if err := chain.preflight(cfg, requests); err != nil {
+		t.Errorf("preflight() error = %v, want it allowed with budget.allow_unpriced", err)
+	}
+
+	if err := (&modelChain{models: models}).preflight(cfg, requests); err != nil {
+		t.Errorf("preflight() error = %v, want no check without a budget", err)
+	}
+}
@@ -0,0 +1,181 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/username/pseudolang/internal/logging"
+)
+
+// DefaultChunkTokens is the target size of one chunk in chunked compilation
+const DefaultChunkTokens = 2000
+
+// LargeInputTokens is the source size above which chunked compilation is
+// suggested for a run that did not ask for it
+const LargeInputTokens = 8000
+
+// maxConcurrentChunks limits how many chunks are translated at once
+const maxConcurrentChunks = 4
+
+// Chunk is a group of consecutive units translated in one request
+type Chunk struct {
+	Units []Unit
+}
+
+// Text returns the pseudocode of every unit in the chunk
+func (c Chunk) Text() string {
+	parts := make([]string, len(c.Units))
+	for i, unit := range c.Units {
+		parts[i] = unit.Text
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// SplitIntoChunks groups units into chunks of roughly maxTokens tokens each.
+// Units are never split, so a single oversized unit becomes its own chunk.
+func SplitIntoChunks(units []Unit, maxTokens int, countTokens func(string) int) []Chunk {
+	var chunks []Chunk
+	var current Chunk
+	size := 0
+
+	for _, unit := range units {
+		tokens := countTokens(unit.Text)
+		if len(current.Units) > 0 && size+tokens > maxTokens {
+			chunks = append(chunks, current)
+			current, size = Chunk{}, 0
+		}
+		current.Units = append(current.Units, unit)
+		size += tokens
+	}
+
+	if len(current.Units) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// translateChunked splits input at top-level definitions, translates the
+// chunks concurrently against a shared interface summary and assembles the
+// results into a single module
+func translateChunked(ctx context.Context, generate generateFunc, input string, chunkTokens int) (string, error) {
+	units := SplitTopLevel(input)
+	if len(units) == 0 {
+		return "", fmt.Errorf("no pseudocode to translate")
+	}
+
+	chunks := SplitIntoChunks(units, chunkTokens, CountTokens)
+	return translateChunks(ctx, generate, chunks, InterfaceSummary(units))
+}
+
+// translateChunks translates each chunk in its own request and assembles
+// the results in chunk order
+func translateChunks(ctx context.Context, generate generateFunc, chunks []Chunk, summary string) (string, error) {
+	_, span := logging.Start(ctx, "prompt.build", "prompts", len(chunks))
+	prompts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		prompts[i] = BuildChunkPrompt(chunk.Text(), summary, i+1, len(chunks))
+	}
+	span.End(nil)
+
+	results, err := translateAll(ctx, generate, prompts, "chunk")
+	if err != nil {
+		return "", err
+	}
+
+	return AssembleModule(results), nil
+}
+
+// translateAll translates every prompt, at most maxConcurrentChunks at a
+// time, and returns the Python code in prompt order. what names a piece in
+// error messages. The first failure cancels the requests still running or
+// waiting, since their results would be thrown away.
+func translateAll(ctx context.Context, generate generateFunc, prompts []string, what string) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]string, len(prompts))
+	semaphore := make(chan struct{}, maxConcurrentChunks)
+
+	var (
+		failOnce sync.Once
+		failed   = -1
+		failure  error
+	)
+	fail := func(i int, err error) {
+		failOnce.Do(func() {
+			failed, failure = i, err
+			cancel()
+		})
+	}
+
+	var wg sync.WaitGroup
+	for i, prompt := range prompts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			if ctx.Err() != nil {
+				return
+			}
+			result, err := translate(ctx, generate, prompt)
+			if err != nil {
+				fail(i, err)
+				return
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	if failed >= 0 {
+		return nil, fmt.Errorf("%s %d of %d: %w", what, failed+1, len(prompts), failure)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// AssembleModule joins separately translated Python fragments into one
+// module, hoisting and de-duplicating their top-level imports
+func AssembleModule(fragments []string) string {
+	var imports, bodies []string
+	seen := make(map[string]bool)
+
+	for _, fragment := range fragments {
+		var body []string
+		for _, line := range strings.Split(fragment, "\n") {
+			if isSingleLineImport(line) {
+				if !seen[line] {
+					seen[line] = true
+					imports = append(imports, line)
+				}
+				continue
+			}
+			body = append(body, line)
+		}
+
+		if text := strings.TrimSpace(strings.Join(body, "\n")); text != "" {
+			bodies = append(bodies, text)
+		}
+	}
+
+	var module []string
+	if len(imports) > 0 {
+		module = append(module, strings.Join(imports, "\n"))
+	}
+	module = append(module, bodies...)
+	return strings.Join(module, "\n\n\n") + "\n"
+}
+
+func isSingleLineImport(line string) bool {
+	if !strings.HasPrefix(line, "import ") && !strings.HasPrefix(line, "from ") {
+		return false
+	}
+	trimmed := strings.TrimSpace(line)
+	return !strings.HasSuffix(trimmed, "(") && !strings.HasSuffix(trimmed, "\\")
+}
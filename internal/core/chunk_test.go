@@ -0,0 +1,157 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func countWords(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestSplitIntoChunks(t *testing.T) {
+	units := []Unit{
+		{Text: "one two three"},
+		{Text: "four five"},
+		{Text: "six seven eight nine ten"},
+		{Text: "eleven"},
+	}
+
+	tests := []struct {
+		name      string
+		maxTokens int
+		want      []int
+	}{
+		{name: "everything fits", maxTokens: 100, want: []int{4}},
+		{name: "grouped by budget", maxTokens: 5, want: []int{2, 1, 1}},
+		{name: "oversized units stand alone", maxTokens: 1, want: []int{1, 1, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := SplitIntoChunks(units, tt.maxTokens, countWords)
+			var got []int
+			for _, chunk := range chunks {
+				got = append(got, len(chunk.Units))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("SplitIntoChunks() chunk sizes = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("SplitIntoChunks() chunk sizes = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestAssembleModule(t *testing.T) {
+	fragments := []string{
+		"import math\nfrom typing import List\n\ndef area(r):\n    return math.pi * r * r",
+		"import math\n\ndef circumference(r):\n    return 2 * math.pi * r",
+		"from typing import (\n    Dict,\n)\nprint(area(1))",
+	}
+
+	want := `import math
+from typing import List
+
+
+def area(r):
+    return math.pi * r * r
+
+
+def circumference(r):
+    return 2 * math.pi * r
+
+
+from typing import (
+    Dict,
+)
+print(area(1))
+`
+
+	if got := AssembleModule(fragments); got != want {
+		t.Errorf("AssembleModule() = %q, want %q", got, want)
+	}
+}
+
+func TestTranslateChunks(t *testing.T) {
+	chunks := []Chunk{
+		{Units: []Unit{{Kind: UnitDefinition, Name: "a", Signature: "function a()", Text: "function a():\n    return 1"}}},
+		{Units: []Unit{{Kind: UnitStatements, Text: "print a()"}}},
+	}
+	summary := InterfaceSummary(append(chunks[0].Units, chunks[1].Units...))
+
+	generate := func(ctx context.Context, prompt string) (string, error) {
+		if !strings.Contains(prompt, "<interface>\nfunction a()\n</interface>") {
+			return "", errors.New("prompt is missing the interface summary")
+		}
+		switch {
+		case strings.Contains(prompt, "part 1 of 2"):
+			return "<code>\ndef a():\n    return 1\n</code>", nil
+		case strings.Contains(prompt, "part 2 of 2"):
+			return "<code>\nprint(a())\n</code>", nil
+		}
+		return "", errors.New("unexpected prompt")
+	}
+
+	got, err := translateChunks(context.Background(), generate, chunks, summary)
+	if err != nil {
+		t.Fatalf("translateChunks() unexpected error = %v", err)
+	}
+
+	want := "def a():\n    return 1\n\n\nprint(a())\n"
+	if got != want {
+		t.Errorf("translateChunks() = %q, want %q", got, want)
+	}
+}
+
+func TestTranslateChunksReportsFailingChunk(t *testing.T) {
+	chunks := []Chunk{
+		{Units: []Unit{{Text: "a"}}},
+		{Units: []Unit{{Text: "b"}}},
+	}
+
+	generate := func(ctx context.Context, prompt string) (string, error) {
+		if strings.Contains(prompt, "part 2 of 2") {
+			return "", errors.New("rate limited")
+		}
+		return "<code>\npass\n</code>", nil
+	}
+
+	_, err := translateChunks(context.Background(), generate, chunks, "")
+	if err == nil || !strings.Contains(err.Error(), "chunk 2 of 2") {
+		t.Errorf("translateChunks() error = %v, want error naming chunk 2 of 2", err)
+	}
+}
+
+func TestTranslateAllCancelsOnFailure(t *testing.T) {
+	prompts := []string{"slow", "fails", "slow", "slow"}
+
+	generate := func(ctx context.Context, prompt string) (string, error) {
+		if prompt == "fails" {
+			return "", errors.New("bad request")
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(5 * time.Second):
+			return "<code>\npass\n</code>", nil
+		}
+	}
+
+	start := time.Now()
+	_, err := translateAll(context.Background(), generate, prompts, "chunk")
+	if err == nil || !strings.Contains(err.Error(), "chunk 2 of 4: failed to generate response: bad request") {
+		t.Errorf("translateAll() error = %v, want the failing chunk's error", err)
+	}
+	// The other requests are cancelled or never sent rather than waited for
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("translateAll() took %s, want the other requests cancelled", elapsed)
+	}
+}
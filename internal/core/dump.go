@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/username/pseudolang/internal/logging"
+)
+
+// requestDump writes the full prompt and raw response of every model request
+// of a run to a directory, for debugging a translation. Secrets are redacted
+// as they are from the log, and the files are readable by their owner only.
+type requestDump struct {
+	dir string
+
+	mu   sync.Mutex
+	next int
+}
+
+type dumpKey struct{}
+
+// unsafeFileCharsRe matches the characters of provider and model names that
+// cannot go in a file name
+var unsafeFileCharsRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// withDump returns a context whose model requests are dumped to dir. Nothing
+// is dumped when dir is empty. Numbering continues after the requests
+// already in dir, so several runs can share it.
+func withDump(ctx context.Context, dir string) (context.Context, error) {
+	if dir == "" {
+		return ctx, nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create dump directory: %w", err)
+	}
+
+	existing, _ := filepath.Glob(filepath.Join(dir, "*.prompt.txt"))
+	return context.WithValue(ctx, dumpKey{}, &requestDump{dir: dir, next: len(existing) + 1}), nil
+}
+
+// dumpRequest starts dumping one request to model at provider, writing its
+// prompt, and returns the function that writes its response or error. It
+// does nothing when ctx has no dump.
+func dumpRequest(ctx context.Context, provider, model, prompt string) func(response string, err error) {
+	dump, ok := ctx.Value(dumpKey{}).(*requestDump)
+	if !ok {
+		return func(string, error) {}
+	}
+
+	dump.mu.Lock()
+	n := dump.next
+	dump.next++
+	dump.mu.Unlock()
+
+	base := filepath.Join(dump.dir, fmt.Sprintf("%03d-%s", n, unsafeFileCharsRe.ReplaceAllString(provider+"-"+model, "_")))
+	dump.write(base+".prompt.txt", prompt)
+
+	return func(response string, err error) {
+		if err != nil {
+			dump.write(base+".error.txt", err.Error()+"\n")
+			return
+		}
+		dump.write(base+".response.txt", response)
+	}
+}
+
+// write writes one dump file, warning rather than failing the run when it
+// cannot
+func (d *requestDump) write(path, text string) {
+	if err := os.WriteFile(path, []byte(logging.Redact(text)), 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to dump request: %v\n", err)
+		return
+	}
+	logging.Logger().Debug("dumped request", "path", path)
+}
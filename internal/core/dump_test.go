@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDumpRequest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dump")
+
+	ctx, err := withDump(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("withDump() error = %v", err)
+	}
+	dumpRequest(ctx, "ollama", "llama3:8b", "first prompt")("raw response", nil)
+	dumpRequest(ctx, "openai", "gpt-4o", "second prompt")("", errors.New("status 401: invalid key sk-test1234567890"))
+
+	// A later run carries on the numbering
+	ctx, err = withDump(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("withDump() error = %v", err)
+	}
+	dumpRequest(ctx, "openai", "gpt-4o", "third prompt")("", nil)
+
+	want := map[string]string{
+		"001-ollama-llama3_8b.prompt.txt":   "first prompt",
+		"001-ollama-llama3_8b.response.txt": "raw response",
+		"002-openai-gpt-4o.prompt.txt":      "second prompt",
+		"002-openai-gpt-4o.error.txt":       "status 401: invalid key [REDACTED]\n",
+		"003-openai-gpt-4o.prompt.txt":      "third prompt",
+		"003-openai-gpt-4o.response.txt":    "",
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != len(want) {
+		t.Errorf("dumped %d files, want %d", len(entries), len(want))
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, content)
+		}
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && info.Mode().Perm() != 0o600 {
+			t.Errorf("%s mode = %v, want 0600", name, info.Mode().Perm())
+		}
+	}
+}
+
+func TestDumpRequestWithoutDump(t *testing.T) {
+	ctx, err := withDump(context.Background(), "")
+	if err != nil || ctx != context.Background() {
+		t.Fatalf("withDump(\"\") = %v, %v, want the context unchanged", ctx, err)
+	}
+	// Nothing to write to; this must not panic
+	dumpRequest(ctx, "openai", "gpt-4o", "prompt")("response", nil)
+}
@@ -3,11 +3,46 @@ package core
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/username/pseudolang/internal/logging"
 )
 
+// PythonPackageName is the package that multi-file programs are written to
+const PythonPackageName = "pseudoprogram"
+
+// cleanEnvVars are the only variables passed through when running with a clean environment
+var cleanEnvVars = []string{"PATH", "HOME", "LANG", "LC_ALL", "TMPDIR", "TEMP", "TMP", "SYSTEMROOT"}
+
+// RunOptions controls how generated Python is run
+type RunOptions struct {
+	// Interpreter is the Python executable; python3 or python on PATH when empty
+	Interpreter string
+	// Timeout stops the program after this long; no limit when zero
+	Timeout time.Duration
+	// CleanEnv runs the program with only a minimal environment
+	CleanEnv bool
+	// Stdout and Stderr receive the program's output; os.Stdout and os.Stderr when nil
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// ExitError is returned when the Python program exits with a failure status
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("python execution failed (exit code %d)", e.Code)
+}
+
 // FindPythonInterpreter locates an available Python interpreter
 func FindPythonInterpreter() (string, error) {
 	interpreters := []string{"python3", "python"}
@@ -23,12 +58,7 @@ func FindPythonInterpreter() (string, error) {
 }
 
 // ExecutePythonCode executes Python code and returns the output
-func ExecutePythonCode(ctx context.Context, code string) error {
-	pythonPath, err := FindPythonInterpreter()
-	if err != nil {
-		return err
-	}
-
+func ExecutePythonCode(ctx context.Context, code string, run RunOptions) error {
 	tmpFile, err := os.CreateTemp("", "pseudolang_*.py")
 	if err != nil {
 		return fmt.Errorf("failed to create temporary file: %w", err)
@@ -46,41 +76,84 @@ func ExecutePythonCode(ctx context.Context, code string) error {
 		return fmt.Errorf("failed to close temporary file: %w", err)
 	}
 
-	cmd := exec.CommandContext(ctx, pythonPath, tmpFile.Name())
+	return runPython(ctx, run, "", tmpFile.Name())
+}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// ExecutePythonFile executes a Python file directly
+func ExecutePythonFile(ctx context.Context, filepath string, run RunOptions) error {
+	return runPython(ctx, run, "", filepath)
+}
 
-	err = cmd.Run()
+// ExecutePythonPackage writes modules (keyed by module name) into a temporary
+// package and runs the entry module with `python -m`. The program runs in
+// the current directory, as single-file programs do, and finds the package
+// through PYTHONPATH.
+func ExecutePythonPackage(ctx context.Context, modules map[string]string, entry string, run RunOptions) error {
+	tmpDir, err := os.MkdirTemp("", "pseudolang_*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
 
-	if stdout.Len() > 0 {
-		fmt.Print(stdout.String())
+	if err := WritePythonPackage(tmpDir, modules); err != nil {
+		return err
 	}
 
-	if stderr.Len() > 0 {
-		fmt.Fprint(os.Stderr, stderr.String())
+	return runPython(ctx, run, tmpDir, "-m", PythonPackageName+"."+entry)
+}
+
+// WritePythonPackage writes modules (keyed by module name) as the
+// PythonPackageName package inside dir
+func WritePythonPackage(dir string, modules map[string]string) error {
+	packageDir := filepath.Join(dir, PythonPackageName)
+	if err := os.MkdirAll(packageDir, 0755); err != nil {
+		return fmt.Errorf("failed to create package directory: %w", err)
 	}
 
-	if err != nil {
-		exitMsg := ""
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitMsg = fmt.Sprintf(" (exit code %d)", exitErr.ExitCode())
+	if err := os.WriteFile(filepath.Join(packageDir, "__init__.py"), nil, 0644); err != nil {
+		return fmt.Errorf("failed to write package: %w", err)
+	}
+
+	for module, code := range modules {
+		if err := os.WriteFile(filepath.Join(packageDir, module+".py"), []byte(code), 0644); err != nil {
+			return fmt.Errorf("failed to write Python module %s: %w", module, err)
 		}
-		return fmt.Errorf("python execution failed%s", exitMsg)
 	}
 
 	return nil
 }
 
-// ExecutePythonFile executes a Python file directly
-func ExecutePythonFile(ctx context.Context, filepath string) error {
-	pythonPath, err := FindPythonInterpreter()
-	if err != nil {
-		return err
+// runPython runs the interpreter with args in the current directory,
+// forwarding its output. A non-empty importDir is put first on PYTHONPATH.
+func runPython(ctx context.Context, run RunOptions, importDir string, args ...string) (err error) {
+	ctx, span := logging.Start(ctx, "execution", "timeout", run.Timeout, "clean_env", run.CleanEnv)
+	defer func() { span.End(err) }()
+
+	pythonPath := run.Interpreter
+	if pythonPath == "" {
+		found, err := FindPythonInterpreter()
+		if err != nil {
+			return err
+		}
+		pythonPath = found
 	}
 
-	cmd := exec.CommandContext(ctx, pythonPath, filepath)
+	if run.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, run.Timeout)
+		defer cancel()
+	}
+
+	span.Add("interpreter", pythonPath)
+	cmd := exec.CommandContext(ctx, pythonPath, args...)
+	if run.CleanEnv {
+		cmd.Env = cleanEnv()
+	}
+	if importDir != "" {
+		cmd.Env = withPythonPath(cmd.Env, importDir)
+	}
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -88,21 +161,64 @@ func ExecutePythonFile(ctx context.Context, filepath string) error {
 
 	err = cmd.Run()
 
+	stdoutWriter, stderrWriter := run.Stdout, run.Stderr
+	if stdoutWriter == nil {
+		stdoutWriter = os.Stdout
+	}
+	if stderrWriter == nil {
+		stderrWriter = os.Stderr
+	}
+
 	if stdout.Len() > 0 {
-		fmt.Print(stdout.String())
+		_, _ = stdoutWriter.Write(stdout.Bytes())
 	}
 
 	if stderr.Len() > 0 {
-		fmt.Fprint(os.Stderr, stderr.String())
+		_, _ = stderrWriter.Write(stderr.Bytes())
+	}
+	span.Add("stdout_bytes", stdout.Len(), "stderr_bytes", stderr.Len())
+
+	if run.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("python execution timed out after %s", run.Timeout)
 	}
 
 	if err != nil {
-		exitMsg := ""
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitMsg = fmt.Sprintf(" (exit code %d)", exitErr.ExitCode())
+			return &ExitError{Code: exitErr.ExitCode()}
 		}
-		return fmt.Errorf("python execution failed%s", exitMsg)
+		return fmt.Errorf("python execution failed")
 	}
 
 	return nil
 }
+
+// withPythonPath returns env, or the current environment when env is nil,
+// with dir first on PYTHONPATH
+func withPythonPath(env []string, dir string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+
+	pythonPath := dir
+	kept := make([]string, 0, len(env)+1)
+	for _, variable := range env {
+		if existing, ok := strings.CutPrefix(variable, "PYTHONPATH="); ok {
+			if existing != "" {
+				pythonPath += string(os.PathListSeparator) + existing
+			}
+			continue
+		}
+		kept = append(kept, variable)
+	}
+	return append(kept, "PYTHONPATH="+pythonPath)
+}
+
+func cleanEnv() []string {
+	var env []string
+	for _, name := range cleanEnvVars {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWithPythonPath(t *testing.T) {
+	env := withPythonPath([]string{"PATH=/usr/bin", "PYTHONPATH=/opt/lib"}, "/tmp/pkg")
+	want := []string{"PATH=/usr/bin", "PYTHONPATH=/tmp/pkg" + string(os.PathListSeparator) + "/opt/lib"}
+	if !slices.Equal(env, want) {
+		t.Errorf("withPythonPath() = %q, want %q", env, want)
+	}
+
+	if env := withPythonPath([]string{"HOME=/home/me"}, "/tmp/pkg"); !slices.Contains(env, "PYTHONPATH=/tmp/pkg") {
+		t.Errorf("withPythonPath() without PYTHONPATH = %q, want it added", env)
+	}
+}
+
+func TestExecutePythonPackage_RunsInCurrentDirectory(t *testing.T) {
+	if _, err := FindPythonInterpreter(); err != nil {
+		t.Skip("python is not installed")
+	}
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile("data.csv", []byte("a,b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	modules := map[string]string{
+		"helpers": "def first_line(path):\n    with open(path) as f:\n        return f.readline().strip()\n",
+		"main":    "from .helpers import *\nprint(first_line('data.csv'))\n",
+	}
+
+	for _, clean := range []bool{false, true} {
+		var stdout strings.Builder
+		err := ExecutePythonPackage(context.Background(), modules, "main", RunOptions{Stdout: &stdout, Stderr: &stdout, CleanEnv: clean})
+		if err != nil || stdout.String() != "a,b\n" {
+			t.Errorf("ExecutePythonPackage(clean env %v) = %q, %v, want it to read data.csv from the current directory", clean, stdout.String(), err)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// codeExtractor pulls Python code out of an LLM response in one specific format
+type codeExtractor struct {
+	name    string
+	extract func(response string) (string, error)
+}
+
+// codeExtractors are tried in order and the first one that yields code wins.
+// Within a single extractor the first non-empty candidate in document order is
+// used, except for fenced blocks where python-labelled fences beat unlabelled ones.
+var codeExtractors = []codeExtractor{
+	{name: "<code> tags", extract: extractFromCodeTags},
+	{name: "fenced blocks", extract: extractFromFencedBlocks},
+	{name: "json", extract: extractFromJSON},
+}
+
+var (
+	analysisRe = regexp.MustCompile(`(?s)<conversion_analysis>.*?</conversion_analysis>`)
+	codeTagRe  = regexp.MustCompile(`<(/?)code(?:\s[^>]*)?>`)
+)
+
+var pythonFenceLabels = map[string]bool{
+	"python":  true,
+	"python3": true,
+	"py":      true,
+}
+
+// ExtractPythonCode parses the LLM response and extracts the Python code.
+// It understands <code> tags, markdown fenced blocks and JSON structured output,
+// and reports why each format failed when no code can be found.
+func ExtractPythonCode(response string) (string, error) {
+	// Snippets quoted in the analysis are never the final program
+	body := analysisRe.ReplaceAllString(response, "")
+
+	var reasons []string
+	for _, extractor := range codeExtractors {
+		code, err := extractor.extract(body)
+		if err == nil {
+			return code, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %v", extractor.name, err))
+	}
+
+	return "", fmt.Errorf("no code found in response (%s)", strings.Join(reasons, "; "))
+}
+
+// extractFromCodeTags returns the first non-empty outermost <code> block.
+// Nested <code> tags inside a block are dropped and a fenced block wrapped in
+// tags is unwrapped.
+func extractFromCodeTags(response string) (string, error) {
+	var blocks []string
+	depth, start := 0, 0
+
+	for _, loc := range codeTagRe.FindAllStringSubmatchIndex(response, -1) {
+		closing := loc[3] > loc[2]
+		switch {
+		case !closing:
+			if depth == 0 {
+				start = loc[1]
+			}
+			depth++
+		case depth > 0:
+			depth--
+			if depth == 0 {
+				blocks = append(blocks, response[start:loc[0]])
+			}
+		}
+	}
+
+	if len(blocks) == 0 {
+		return "", fmt.Errorf("no <code> tags found in response")
+	}
+
+	for _, block := range blocks {
+		code := codeTagRe.ReplaceAllString(block, "")
+		code = strings.TrimSpace(unwrapFence(code))
+		if code != "" {
+			return code, nil
+		}
+	}
+
+	return "", fmt.Errorf("code block is empty")
+}
+
+// fencedBlock is a markdown code fence and its info-string label
+type fencedBlock struct {
+	label string
+	body  string
+}
+
+// extractFromFencedBlocks returns the first python-labelled fenced block, or
+// the first unlabelled block when none is labelled python. Fences labelled
+// with other languages are ignored.
+func extractFromFencedBlocks(response string) (string, error) {
+	blocks := findFencedBlocks(response)
+	if len(blocks) == 0 {
+		return "", fmt.Errorf("no fenced code blocks found")
+	}
+
+	var python, unlabelled []string
+	var others []string
+	for _, block := range blocks {
+		switch {
+		case pythonFenceLabels[block.label]:
+			python = append(python, block.body)
+		case block.label == "":
+			unlabelled = append(unlabelled, block.body)
+		default:
+			others = append(others, block.label)
+		}
+	}
+
+	candidates := append(python, unlabelled...)
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no python fenced code blocks found (found: %s)", strings.Join(others, ", "))
+	}
+
+	for _, candidate := range candidates {
+		if code := strings.TrimSpace(candidate); code != "" {
+			return code, nil
+		}
+	}
+
+	return "", fmt.Errorf("code block is empty")
+}
+
+// findFencedBlocks scans for closed ``` or ~~~ fences in document order
+func findFencedBlocks(text string) []fencedBlock {
+	var blocks []fencedBlock
+	lines := strings.Split(text, "\n")
+
+	for i := 0; i < len(lines); i++ {
+		marker, label, ok := parseFenceOpen(lines[i])
+		if !ok {
+			continue
+		}
+
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == marker {
+				blocks = append(blocks, fencedBlock{
+					label: label,
+					body:  strings.Join(lines[i+1:j], "\n"),
+				})
+				i = j
+				break
+			}
+		}
+	}
+
+	return blocks
+}
+
+// parseFenceOpen reports whether line opens a fence, returning the fence
+// marker and the lower-cased language label
+func parseFenceOpen(line string) (string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	for _, marker := range []string{"```", "~~~"} {
+		if strings.HasPrefix(trimmed, marker) {
+			fields := strings.Fields(strings.TrimPrefix(trimmed, marker))
+			label := ""
+			if len(fields) > 0 {
+				label = strings.ToLower(fields[0])
+			}
+			return marker, label, true
+		}
+	}
+	return "", "", false
+}
+
+// unwrapFence strips a single surrounding fence from text, if present
+func unwrapFence(text string) string {
+	blocks := findFencedBlocks(strings.TrimSpace(text))
+	if len(blocks) != 1 {
+		return text
+	}
+
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "```") && !strings.HasPrefix(trimmed, "~~~") {
+		return text
+	}
+	return blocks[0].body
+}
+
+// extractFromJSON returns the "code" field of the first JSON object in the
+// response that has a non-empty one
+func extractFromJSON(response string) (string, error) {
+	foundObject := false
+
+	for i := 0; i < len(response); i++ {
+		if response[i] != '{' {
+			continue
+		}
+
+		var object map[string]any
+		decoder := json.NewDecoder(strings.NewReader(response[i:]))
+		if err := decoder.Decode(&object); err != nil {
+			continue
+		}
+		foundObject = true
+
+		for _, key := range []string{"code", "python_code", "python"} {
+			if code, ok := object[key].(string); ok && strings.TrimSpace(code) != "" {
+				return strings.TrimSpace(code), nil
+			}
+		}
+
+		i += int(decoder.InputOffset()) - 1
+	}
+
+	if !foundObject {
+		return "", fmt.Errorf("no JSON object found")
+	}
+	return "", fmt.Errorf("no JSON object with a non-empty \"code\" field found")
+}
+
+var (
+	// assumptionHeadingRe matches a heading in the analysis: a markdown
+	// heading, a bold line, or a line, numbered or not, that starts with a
+	// short label and a colon
+	assumptionHeadingRe = regexp.MustCompile(`^(?:#+\s|\*\*.*\*\*:?$|(?:\d+[.)]\s+)?(?:\*\*|[A-Za-z][^:]{0,39}:))`)
+	listMarkerRe        = regexp.MustCompile(`^(?:[-*•]|\d+[.)])\s+`)
+)
+
+// ExtractAssumptions returns the assumptions the model states in the
+// conversion analysis of response: the items under a heading that mentions
+// assumptions, and any other line that mentions one. It returns nil when the
+// response has no analysis.
+func ExtractAssumptions(response string) []string {
+	_, analysis, found := strings.Cut(response, "<conversion_analysis>")
+	if !found {
+		return nil
+	}
+	analysis, _, _ = strings.Cut(analysis, "</conversion_analysis>")
+
+	var assumptions []string
+	inSection := false
+	for _, line := range strings.Split(analysis, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		mentions := mentionsAssumption(trimmed)
+
+		// Only unindented lines can be headings; indented ones are the items
+		// under them
+		if line == strings.TrimLeft(line, " \t") && assumptionHeadingRe.MatchString(trimmed) {
+			label, after, _ := strings.Cut(trimmed, ":")
+			inSection = mentionsAssumption(label)
+			// A heading may carry its assumption after the colon
+			if !inSection && mentions {
+				after = trimmed
+			}
+			if inSection || mentions {
+				if item := cleanAssumption(after); item != "" {
+					assumptions = append(assumptions, item)
+				}
+			}
+			continue
+		}
+
+		if inSection || mentions {
+			if item := cleanAssumption(trimmed); item != "" {
+				assumptions = append(assumptions, item)
+			}
+		}
+	}
+	return assumptions
+}
+
+func mentionsAssumption(text string) bool {
+	return strings.Contains(strings.ToLower(text), "assum")
+}
+
+// cleanAssumption strips list markers and emphasis from an analysis line
+func cleanAssumption(line string) string {
+	line = listMarkerRe.ReplaceAllString(strings.TrimSpace(line), "")
+	line = strings.ReplaceAll(line, "**", "")
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// chainModel is one model a modelChain can generate with
+type chainModel struct {
+	provider string
+	model    string
+	// settings names the generation settings the model is used with
+	settings string
+	// connect builds the generator the first time the model is needed
+	connect  func() (generateFunc, error)
+	generate generateFunc
+}
+
+func (m *chainModel) String() string {
+	return m.provider + "/" + m.model
+}
+
+// modelChain generates with the active model and, when it is unavailable
+// after retrying, with each fallback model in turn. Once a model has been
+// given up on, later requests go straight to the next one.
+type modelChain struct {
+	models []*chainModel
+	policy RetryPolicy
+	// notify receives a line whenever the chain falls back; nil discards them
+	notify io.Writer
+	// meter adds up what every model in the chain used
+	meter *UsageMeter
+	// budgets stop the chain from making requests once the run has spent
+	// more than it may
+	budgets budgets
+
+	mu      sync.Mutex
+	current int
+	// assumptions are those stated in the responses so far
+	assumptions []string
+}
+
+// generate is the chain's generateFunc
+func (c *modelChain) generate(ctx context.Context, prompt string) (string, error) {
+	if err := c.budgets.check(c.meter.Cost(), false); err != nil {
+		return "", err
+	}
+
+	for {
+		index, m, err := c.model()
+		if err == nil {
+			var out string
+			out, err = c.policy.do(ctx, func() (string, error) {
+				return m.generate(ctx, prompt)
+			})
+			if err == nil {
+				c.noteAssumptions(out)
+				return out, nil
+			}
+			if !isUnavailable(err) {
+				return "", err
+			}
+		}
+
+		if !c.fallBack(index, m, err) {
+			return "", err
+		}
+	}
+}
+
+// model returns the current model, connecting to it if needed
+func (c *modelChain) model() (int, *chainModel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := c.models[c.current]
+	if m.generate == nil {
+		generate, err := m.connect()
+		if err != nil {
+			return c.current, m, fmt.Errorf("%s: %w", m, err)
+		}
+		m.generate = generate
+	}
+	return c.current, m, nil
+}
+
+// fallBack moves past the model at index after it failed with err. It
+// reports false when there is no model left to try.
+func (c *modelChain) fallBack(index int, m *chainModel, err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another request may already have moved on
+	if c.current > index {
+		return true
+	}
+	if index+1 >= len(c.models) {
+		return false
+	}
+
+	c.current = index + 1
+	if c.notify != nil {
+		fmt.Fprintf(c.notify, "%s is unavailable (%v); falling back to %s\n", m, err, c.models[c.current])
+	}
+	return true
+}
+
+// noteAssumptions keeps the assumptions stated in response, skipping ones
+// already seen
+func (c *modelChain) noteAssumptions(response string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, assumption := range ExtractAssumptions(response) {
+		if !slices.Contains(c.assumptions, assumption) {
+			c.assumptions = append(c.assumptions, assumption)
+		}
+	}
+}
+
+// used returns the model that generated the most recent responses, and
+// whether it is a fallback
+func (c *modelChain) used() (*chainModel, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.models[c.current], c.current > 0
+}
+
+// report tells the user which model produced the code: always when it was a
+// fallback, and otherwise only when verbose
+func (c *modelChain) report(w io.Writer, verbose bool) {
+	m, fallback := c.used()
+	switch {
+	case fallback:
+		fmt.Fprintf(w, "Code generated by fallback model %s\n", m)
+	case verbose:
+		fmt.Fprintf(w, "Code generated by %s\n", m)
+	}
+}
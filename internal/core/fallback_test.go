@@ -0,0 +1,115 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeModel returns a chain model that fails with status until it has been
+// called failures times
+func fakeModel(name string, status, failures int, calls *int) *chainModel {
+	return &chainModel{
+		provider: "test",
+		model:    name,
+		generate: func(ctx context.Context, prompt string) (string, error) {
+			*calls++
+			if *calls <= failures {
+				return "", &APIError{Provider: "test", Model: name, StatusCode: status}
+			}
+			return name, nil
+		},
+	}
+}
+
+func TestModelChain_FallsBack(t *testing.T) {
+	var delays []time.Duration
+	var primaryCalls, fallbackCalls int
+	var notices strings.Builder
+
+	chain := &modelChain{
+		models: []*chainModel{
+			fakeModel("primary", http.StatusServiceUnavailable, 100, &primaryCalls),
+			fakeModel("fallback", 0, 0, &fallbackCalls),
+		},
+		policy: testPolicy(1, &delays),
+		notify: &notices,
+	}
+
+	for i := 0; i < 2; i++ {
+		got, err := chain.generate(context.Background(), "prompt")
+		if err != nil {
+			t.Fatalf("generate() unexpected error = %v", err)
+		}
+		if got != "fallback" {
+			t.Errorf("generate() = %q, want the fallback's response", got)
+		}
+	}
+
+	if primaryCalls != 2 {
+		t.Errorf("primary called %d times, want 2 (one retry, then never again)", primaryCalls)
+	}
+	if !strings.Contains(notices.String(), "falling back to test/fallback") {
+		t.Errorf("notices = %q, want the fallback announced", notices.String())
+	}
+
+	var report strings.Builder
+	chain.report(&report, false)
+	if report.String() != "Code generated by fallback model test/fallback\n" {
+		t.Errorf("report() = %q, want the fallback model named", report.String())
+	}
+}
+
+func TestModelChain_DoesNotFallBackOnClientErrors(t *testing.T) {
+	var delays []time.Duration
+	var primaryCalls, fallbackCalls int
+
+	chain := &modelChain{
+		models: []*chainModel{
+			fakeModel("primary", http.StatusUnauthorized, 100, &primaryCalls),
+			fakeModel("fallback", 0, 0, &fallbackCalls),
+		},
+		policy: testPolicy(3, &delays),
+		notify: io.Discard,
+	}
+
+	if _, err := chain.generate(context.Background(), "prompt"); err == nil {
+		t.Fatalf("generate() expected error but got none")
+	}
+	if primaryCalls != 1 || fallbackCalls != 0 {
+		t.Errorf("calls = %d primary, %d fallback, want 1 and 0", primaryCalls, fallbackCalls)
+	}
+}
+
+func TestModelChain_ConnectsFallbacksLazily(t *testing.T) {
+	var delays []time.Duration
+	var primaryCalls int
+	connected := false
+
+	chain := &modelChain{
+		models: []*chainModel{
+			fakeModel("primary", http.StatusServiceUnavailable, 1, &primaryCalls),
+			{provider: "test", model: "fallback", connect: func() (generateFunc, error) {
+				connected = true
+				return nil, nil
+			}},
+		},
+		policy: testPolicy(1, &delays),
+	}
+
+	if _, err := chain.generate(context.Background(), "prompt"); err != nil {
+		t.Fatalf("generate() unexpected error = %v", err)
+	}
+	if connected {
+		t.Errorf("generate() connected to the fallback although the primary recovered")
+	}
+
+	var report strings.Builder
+	chain.report(&report, false)
+	if report.String() != "" {
+		t.Errorf("report() = %q, want nothing when the primary produced the code", report.String())
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// maxContinuations bounds how many follow-up requests are sent to finish one
+// truncated response
+const maxContinuations = 3
+
+// minContinuationOverlap is the shortest repeated text that is treated as the
+// model re-sending the end of its previous output
+const minContinuationOverlap = 8
+
+// generateFunc sends a single prompt to the model and returns its response
+type generateFunc func(ctx context.Context, prompt string) (string, error)
+
+// IsTruncated reports whether a response was cut off before the model
+// finished writing its code. gollm does not surface the provider's stop
+// reason, so truncation is detected from the shape of the output: an
+// unclosed <code> tag, an unclosed fence, or an analysis with no code after it.
+// Tags and fences are looked for outside the analysis, as they are when the
+// code is extracted.
+func IsTruncated(response string) bool {
+	body := analysisRe.ReplaceAllString(response, "")
+
+	depth := 0
+	sawCodeTag := false
+	for _, match := range codeTagRe.FindAllStringSubmatch(body, -1) {
+		sawCodeTag = true
+		if match[1] == "" {
+			depth++
+		} else if depth > 0 {
+			depth--
+		}
+	}
+	if depth > 0 {
+		return true
+	}
+	if sawCodeTag {
+		return false
+	}
+
+	if hasUnclosedFence(body) {
+		return true
+	}
+
+	return strings.Contains(response, "<conversion_analysis>") &&
+		!strings.Contains(response, "</conversion_analysis>")
+}
+
+// hasUnclosedFence reports whether the last fence opened in text is never closed
+func hasUnclosedFence(text string) bool {
+	open := ""
+	for _, line := range strings.Split(text, "\n") {
+		if open != "" {
+			if strings.TrimSpace(line) == open {
+				open = ""
+			}
+			continue
+		}
+		if marker, _, ok := parseFenceOpen(line); ok {
+			open = marker
+		}
+	}
+	return open != ""
+}
+
+// generateComplete generates a response for prompt and, when it comes back
+// truncated, sends continuation requests and stitches the pieces together
+func generateComplete(ctx context.Context, generate generateFunc, prompt string) (string, error) {
+	response, err := generate(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+
+	for i := 0; i < maxContinuations && IsTruncated(response); i++ {
+		continuation, err := generate(ctx, BuildContinuationPrompt(prompt, response))
+		if err != nil {
+			return "", fmt.Errorf("failed to continue truncated response: %w", err)
+		}
+		if strings.TrimSpace(continuation) == "" {
+			break
+		}
+		response = stitchContinuation(response, continuation)
+	}
+
+	if IsTruncated(response) {
+		return "", fmt.Errorf("response is still truncated after %d continuation requests; try raising max_tokens for this model", maxContinuations)
+	}
+
+	return response, nil
+}
+
+// stitchContinuation appends continuation to partial, dropping any text the
+// model repeated from the end of partial
+func stitchContinuation(partial, continuation string) string {
+	longest := min(len(partial), len(continuation))
+	for n := longest; n >= minContinuationOverlap; n-- {
+		if strings.HasSuffix(partial, continuation[:n]) {
+			return partial + continuation[n:]
+		}
+	}
+	return partial + continuation
+}
@@ -0,0 +1,203 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIsTruncated(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     bool
+	}{
+		{
+			name:     "complete code tags",
+			response: "<code>\nprint(1)\n</code>",
+			want:     false,
+		},
+		{
+			name:     "unclosed code tag",
+			response: "<conversion_analysis>ok</conversion_analysis>\n<code>\nfor i in range(",
+			want:     true,
+		},
+		{
+			name:     "nested tags with outer unclosed",
+			response: "<code>\n<code>\nprint(1)\n</code>\n",
+			want:     true,
+		},
+		{
+			name:     "code tag mentioned in the analysis",
+			response: "<conversion_analysis>The output goes in a <code> block.</conversion_analysis>\n<code>\nprint(1)\n</code>",
+			want:     false,
+		},
+		{
+			name:     "fence mentioned in the analysis",
+			response: "<conversion_analysis>\n```python\n</conversion_analysis>\n```python\nprint(1)\n```",
+			want:     false,
+		},
+		{
+			name:     "unclosed fence",
+			response: "```python\ndef f():\n    return",
+			want:     true,
+		},
+		{
+			name:     "closed fence",
+			response: "```python\nprint(1)\n```",
+			want:     false,
+		},
+		{
+			name:     "cut off during analysis",
+			response: "<conversion_analysis>\n1. The first line declares",
+			want:     true,
+		},
+		{
+			name:     "plain text",
+			response: "I cannot convert this.",
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTruncated(tt.response); got != tt.want {
+				t.Errorf("IsTruncated(%q) = %v, want %v", tt.response, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStitchContinuation(t *testing.T) {
+	tests := []struct {
+		name         string
+		partial      string
+		continuation string
+		want         string
+	}{
+		{
+			name:         "no overlap",
+			partial:      "<code>\nprint(",
+			continuation: "1)\n</code>",
+			want:         "<code>\nprint(1)\n</code>",
+		},
+		{
+			name:         "repeated tail is dropped",
+			partial:      "<code>\nfor item in items:\n",
+			continuation: "for item in items:\n    print(item)\n</code>",
+			want:         "<code>\nfor item in items:\n    print(item)\n</code>",
+		},
+		{
+			name:         "short coincidental overlap is kept",
+			partial:      "x = 1\n",
+			continuation: "\ny = 2",
+			want:         "x = 1\n\ny = 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stitchContinuation(tt.partial, tt.continuation); got != tt.want {
+				t.Errorf("stitchContinuation() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateComplete(t *testing.T) {
+	tests := []struct {
+		name        string
+		responses   []string
+		wantCalls   int
+		want        string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name:      "complete response needs no continuation",
+			responses: []string{"<code>\nprint(1)\n</code>"},
+			wantCalls: 1,
+			want:      "<code>\nprint(1)\n</code>",
+		},
+		{
+			name:      "truncated response is continued",
+			responses: []string{"<code>\nprint(", "1)\n</code>"},
+			wantCalls: 2,
+			want:      "<code>\nprint(1)\n</code>",
+		},
+		{
+			name:      "continued several times",
+			responses: []string{"<code>\na = 1\n", "b = 2\n", "c = 3\n</code>"},
+			wantCalls: 3,
+			want:      "<code>\na = 1\nb = 2\nc = 3\n</code>",
+		},
+		{
+			name:        "gives up after max continuations",
+			responses:   []string{"<code>\n", "a\n", "b\n", "c\n", "d\n"},
+			wantCalls:   1 + maxContinuations,
+			wantErr:     true,
+			errContains: "still truncated",
+		},
+		{
+			name:        "empty continuation stops early",
+			responses:   []string{"<code>\nprint(", ""},
+			wantCalls:   2,
+			wantErr:     true,
+			errContains: "still truncated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prompts []string
+			generate := func(ctx context.Context, prompt string) (string, error) {
+				prompts = append(prompts, prompt)
+				if len(prompts) > len(tt.responses) {
+					return "", errors.New("unexpected call")
+				}
+				return tt.responses[len(prompts)-1], nil
+			}
+
+			got, err := generateComplete(context.Background(), generate, "PROMPT")
+
+			if len(prompts) != tt.wantCalls {
+				t.Errorf("generateComplete() made %d calls, want %d", len(prompts), tt.wantCalls)
+			}
+			for _, prompt := range prompts[1:] {
+				if !strings.Contains(prompt, "PROMPT") || !strings.Contains(prompt, "<partial_response>") {
+					t.Errorf("continuation prompt missing original prompt or partial response: %q", prompt)
+				}
+			}
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("generateComplete() expected error but got none")
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("generateComplete() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("generateComplete() unexpected error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("generateComplete() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateCompleteReturnsGenerateError(t *testing.T) {
+	generate := func(ctx context.Context, prompt string) (string, error) {
+		return "", errors.New("boom")
+	}
+
+	if _, err := generateComplete(context.Background(), generate, "PROMPT"); err == nil || err.Error() != "boom" {
+		t.Errorf("generateComplete() error = %v, want boom", err)
+	}
+}
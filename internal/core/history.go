@@ -0,0 +1,370 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/username/pseudolang/internal/config"
+)
+
+// HistoryDirName is the directory under the state directory that runs are
+// recorded in
+const HistoryDirName = "history"
+
+// maxHistoryRuns is how many runs are kept; older ones are removed as new
+// ones are recorded
+const maxHistoryRuns = 500
+
+// maxRecordedOutput bounds how much of each output stream a record keeps
+const maxRecordedOutput = 1 << 20
+
+// RunRecord is one run in the history: what was translated, by which model,
+// the code it produced and what happened when the code ran
+type RunRecord struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Source is the file the pseudocode came from; empty for exec
+	Source  string `json:"source,omitempty"`
+	Project string `json:"project,omitempty"`
+	// InputHash is the SHA-256 of the pseudocode of every file translated
+	InputHash     string `json:"input_hash"`
+	Provider      string `json:"provider,omitempty"`
+	Model         string `json:"model,omitempty"`
+	PromptVersion string `json:"prompt_version"`
+
+	// Code is the generated Python of a single-file program. Modules holds
+	// the modules of a multi-file program, keyed by module name, and Entry
+	// names the one that is run.
+	Code    string            `json:"code,omitempty"`
+	Modules map[string]string `json:"modules,omitempty"`
+	Entry   string            `json:"entry,omitempty"`
+	// Assumptions are those the model stated in its analysis
+	Assumptions []string `json:"assumptions,omitempty"`
+
+	// Run is how the code was run, so that it can be replayed the same way
+	Run RecordedRun `json:"run"`
+
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	// Error is why the run failed, if it did
+	Error string `json:"error,omitempty"`
+
+	TranslateTime time.Duration `json:"translate_time"`
+	ExecuteTime   time.Duration `json:"execute_time"`
+	Usage         []ModelUsage  `json:"usage,omitempty"`
+
+	// executed is set once the code has been run
+	executed bool
+}
+
+// RecordedRun is the part of RunOptions kept in the history
+type RecordedRun struct {
+	Interpreter string        `json:"interpreter,omitempty"`
+	Timeout     time.Duration `json:"timeout,omitempty"`
+	CleanEnv    bool          `json:"clean_env,omitempty"`
+}
+
+// Options returns the run options the record was run with
+func (r RecordedRun) Options() RunOptions {
+	return RunOptions{Interpreter: r.Interpreter, Timeout: r.Timeout, CleanEnv: r.CleanEnv}
+}
+
+// HasCode reports whether the run got as far as generating code
+func (r *RunRecord) HasCode() bool {
+	return r.Code != "" || len(r.Modules) > 0
+}
+
+// Replay runs the recorded code again, without calling a model
+func (r *RunRecord) Replay(ctx context.Context, run RunOptions) error {
+	switch {
+	case len(r.Modules) > 0:
+		return ExecutePythonPackage(ctx, r.Modules, r.Entry, run)
+	case r.Code != "":
+		return ExecutePythonCode(ctx, r.Code, run)
+	}
+	return fmt.Errorf("run %s did not generate any code", r.ID)
+}
+
+// newRunRecord starts the record of a run translating sources
+func newRunRecord(opts ExecuteOptions, sources ...string) *RunRecord {
+	now := time.Now()
+
+	hash := sha256.New()
+	for _, source := range sources {
+		hash.Write([]byte(source))
+		hash.Write([]byte{0})
+	}
+
+	return &RunRecord{
+		ID:            newRunID(now),
+		Time:          now,
+		Source:        opts.Source,
+		Project:       opts.Project,
+		InputHash:     hex.EncodeToString(hash.Sum(nil)),
+		PromptVersion: PromptVersion(),
+		Run: RecordedRun{
+			Interpreter: opts.Run.Interpreter,
+			Timeout:     opts.Run.Timeout,
+			CleanEnv:    opts.Run.CleanEnv,
+		},
+	}
+}
+
+// newRunID returns an ID that sorts by time, with a random suffix so that
+// runs started in the same second differ
+func newRunID(now time.Time) string {
+	suffix := make([]byte, 2)
+	_, _ = rand.Read(suffix)
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// translated records the model that generated the code, what it used and
+// the assumptions it made
+func (r *RunRecord) translated(chain *modelChain, usage []ModelUsage) {
+	m, _ := chain.used()
+	r.Provider, r.Model = m.provider, m.model
+	r.Usage = usage
+
+	chain.mu.Lock()
+	r.Assumptions = slices.Clone(chain.assumptions)
+	chain.mu.Unlock()
+}
+
+// run translates with translate and runs the result with execute, recording
+// both. The record is saved to the history and, when opts ask for JSON,
+// printed to stdout in place of the program's output.
+func (r *RunRecord) run(opts ExecuteOptions, translate func(ExecuteOptions) error, execute func(RunOptions) error) error {
+	opts.Record = r
+	if opts.JSON {
+		opts.Verbose = false
+		opts.Run.Stdout, opts.Run.Stderr = io.Discard, io.Discard
+	}
+	defer func() {
+		saveRecord(r)
+		if opts.JSON {
+			if err := r.WriteJSON(os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to write JSON output: %v\n", err)
+			}
+		}
+	}()
+
+	start := time.Now()
+	err := translate(opts)
+	r.TranslateTime = time.Since(start)
+	if err != nil {
+		r.fail(err)
+		return err
+	}
+
+	return r.execute(opts.Run, execute)
+}
+
+// execute runs fn with run, recording the program's output, exit code and
+// how long it took
+func (r *RunRecord) execute(run RunOptions, fn func(RunOptions) error) error {
+	stdout := &limitedBuffer{limit: maxRecordedOutput}
+	stderr := &limitedBuffer{limit: maxRecordedOutput}
+	run.Stdout = io.MultiWriter(writerOr(run.Stdout, os.Stdout), stdout)
+	run.Stderr = io.MultiWriter(writerOr(run.Stderr, os.Stderr), stderr)
+
+	start := time.Now()
+	err := fn(run)
+	r.ExecuteTime = time.Since(start)
+	r.executed = true
+	r.Stdout, r.Stderr = stdout.String(), stderr.String()
+	r.fail(err)
+	return err
+}
+
+// fail records err as the reason the run failed
+func (r *RunRecord) fail(err error) {
+	if err == nil {
+		return
+	}
+	r.Error = err.Error()
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		r.ExitCode = exitErr.Code
+	} else {
+		r.ExitCode = -1
+	}
+}
+
+func writerOr(w, fallback io.Writer) io.Writer {
+	if w == nil {
+		return fallback
+	}
+	return w
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the
+// rest
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room < len(p) {
+		b.truncated = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.Buffer.String() + "\n[output truncated]\n"
+	}
+	return b.Buffer.String()
+}
+
+// saveRecord adds r to the default history, warning rather than failing the
+// run when it cannot
+func saveRecord(r *RunRecord) {
+	history, err := DefaultHistory()
+	if err == nil {
+		err = history.Save(r)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record run: %v\n", err)
+	}
+}
+
+// History stores run records as one JSON file per run
+type History struct {
+	dir string
+}
+
+// NewHistory returns the history kept in dir
+func NewHistory(dir string) *History {
+	return &History{dir: dir}
+}
+
+// DefaultHistory returns the history in the state directory
+func DefaultHistory() (*History, error) {
+	dir, err := config.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewHistory(filepath.Join(dir, HistoryDirName)), nil
+}
+
+// Save writes r to the history and removes the oldest runs beyond the
+// number kept
+func (h *History) Save(r *RunRecord) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(h.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(h.dir, r.ID+".json"), data, 0o644); err != nil {
+		return err
+	}
+
+	ids, err := h.ids()
+	if err != nil {
+		return err
+	}
+	for len(ids) > maxHistoryRuns {
+		_ = os.Remove(filepath.Join(h.dir, ids[0]+".json"))
+		ids = ids[1:]
+	}
+	return nil
+}
+
+// ids returns the IDs of the recorded runs, oldest first
+func (h *History) ids() ([]string, error) {
+	entries, err := os.ReadDir(h.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// List returns up to limit runs, newest first. Records that cannot be read
+// are skipped.
+func (h *History) List(limit int) ([]*RunRecord, error) {
+	ids, err := h.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*RunRecord
+	for i := len(ids) - 1; i >= 0 && len(records) < limit; i-- {
+		if record, err := h.load(ids[i]); err == nil {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// Get returns the run whose ID is id or starts with it
+func (h *History) Get(id string) (*RunRecord, error) {
+	ids, err := h.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, candidate := range ids {
+		if candidate == id {
+			return h.load(id)
+		}
+		if strings.HasPrefix(candidate, id) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no run %s in the history", id)
+	case 1:
+		return h.load(matches[0])
+	}
+	return nil, fmt.Errorf("%s matches %d runs; give more of the ID", id, len(matches))
+}
+
+func (h *History) load(id string) (*RunRecord, error) {
+	data, err := os.ReadFile(filepath.Join(h.dir, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var record RunRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to read run %s: %w", id, err)
+	}
+	return &record, nil
+}
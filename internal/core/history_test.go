@@ -0,0 +1,113 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistory_SaveGetList(t *testing.T) {
+	history := NewHistory(t.TempDir())
+
+	if records, err := history.List(10); err != nil || len(records) != 0 {
+		t.Fatalf("List() on an empty history = %v, %v, want none", records, err)
+	}
+
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"20250301-120000-aaaa", "20250301-120000-aabb", "20250302-090000-cccc"} {
+		record := &RunRecord{ID: id, Time: base.Add(time.Duration(i) * time.Hour), Code: fmt.Sprintf("print(%d)", i)}
+		if err := history.Save(record); err != nil {
+			t.Fatalf("Save(%s) error = %v", id, err)
+		}
+	}
+
+	records, err := history.List(2)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(records) != 2 || records[0].ID != "20250302-090000-cccc" || records[1].ID != "20250301-120000-aabb" {
+		t.Errorf("List(2) returned %v, want the two newest runs, newest first", records)
+	}
+
+	record, err := history.Get("20250302")
+	if err != nil || record.Code != "print(2)" {
+		t.Errorf("Get(unique prefix) = %+v, %v, want the third run", record, err)
+	}
+	if _, err := history.Get("20250301-120000-aa"); err == nil || !strings.Contains(err.Error(), "matches 2 runs") {
+		t.Errorf("Get(ambiguous prefix) error = %v, want it to report both matches", err)
+	}
+	if _, err := history.Get("2024"); err == nil {
+		t.Errorf("Get(unknown) succeeded, want an error")
+	}
+}
+
+func TestRunRecord_Execute(t *testing.T) {
+	record := &RunRecord{}
+	var shown strings.Builder
+
+	err := record.execute(RunOptions{Stdout: &shown, Stderr: io.Discard}, func(run RunOptions) error {
+		fmt.Fprint(run.Stdout, "partial output\n")
+		fmt.Fprint(run.Stderr, "Traceback ...\n")
+		return &ExitError{Code: 3}
+	})
+	if err == nil {
+		t.Fatalf("execute() error = nil, want the program's failure")
+	}
+
+	if shown.String() != "partial output\n" {
+		t.Errorf("output shown = %q, want it passed through", shown.String())
+	}
+	if record.Stdout != "partial output\n" || record.Stderr != "Traceback ...\n" {
+		t.Errorf("recorded stdout %q and stderr %q, want both streams", record.Stdout, record.Stderr)
+	}
+	if record.ExitCode != 3 || record.Error == "" {
+		t.Errorf("recorded exit code %d and error %q, want 3 and the error", record.ExitCode, record.Error)
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{limit: 8}
+	fmt.Fprint(b, "12345")
+	fmt.Fprint(b, "67890")
+
+	if got := b.String(); got != "12345678\n[output truncated]\n" {
+		t.Errorf("String() = %q, want the first 8 bytes and a truncation note", got)
+	}
+}
+
+func TestRunRecord_Result(t *testing.T) {
+	record := &RunRecord{ID: "20250301-120000-aaaa", Code: "print(1)", TranslateTime: 1500 * time.Microsecond}
+
+	result := record.Result()
+	if result.Stdout != nil || result.Stderr != nil || result.ExitCode != nil {
+		t.Errorf("Result() of a run that did not execute has stdout %v, stderr %v, exit code %v, want none",
+			result.Stdout, result.Stderr, result.ExitCode)
+	}
+	if result.Assumptions == nil || result.Usage == nil {
+		t.Errorf("Result() assumptions %v and usage %v, want empty lists rather than nil", result.Assumptions, result.Usage)
+	}
+	if result.Timings.TranslateMs != 1.5 || result.Timings.TotalMs != 1.5 {
+		t.Errorf("Result() timings = %+v, want 1.5ms translating", result.Timings)
+	}
+
+	_ = record.execute(RunOptions{Stdout: io.Discard, Stderr: io.Discard}, func(run RunOptions) error {
+		fmt.Fprint(run.Stdout, "1\n")
+		return nil
+	})
+	result = record.Result()
+	if result.Stdout == nil || *result.Stdout != "1\n" || result.ExitCode == nil || *result.ExitCode != 0 {
+		t.Errorf("Result() of an executed run = %+v, want its output and exit code", result)
+	}
+
+	var out strings.Builder
+	if err := record.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	for _, want := range []string{`"code": "print(1)"`, `"assumptions": []`, `"exit_code": 0`, `"stderr": ""`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("WriteJSON() = %s, want it to contain %s", out.String(), want)
+		}
+	}
+}
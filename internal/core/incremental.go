@@ -0,0 +1,159 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/username/pseudolang/internal/config"
+	"github.com/username/pseudolang/internal/logging"
+)
+
+// UnitCache stores the Python translation of individual units, keyed by a
+// hash of the unit's content, the model and settings and the prompts used
+type UnitCache struct {
+	dir string
+}
+
+// NewUnitCache returns a cache that keeps translations in dir
+func NewUnitCache(dir string) *UnitCache {
+	return &UnitCache{dir: dir}
+}
+
+// Get returns the cached translation for key
+func (c *UnitCache) Get(key string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(c.dir, key+".py"))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Put stores the translation for key
+func (c *UnitCache) Put(key, code string) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(c.dir, key+".py"), []byte(code), 0644); err != nil {
+		return fmt.Errorf("failed to write cached translation: %w", err)
+	}
+
+	return nil
+}
+
+// UnitCacheKey identifies the translation of unit in scope, which names the
+// provider, model and generation settings that translate it (see
+// unitCacheScope). The prompt templates are part of the key so prompt
+// changes invalidate the cache, and so are the signatures of the other
+// definitions the unit refers to, so that a unit is translated again when a
+// signature it calls changes.
+func UnitCacheKey(scope, signatures string, unit Unit) string {
+	hash := sha256.New()
+	for _, part := range []string{scope, PseudocodeToPythonPrompt, IncrementalInstructions, signatures, unit.Text} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// unitCacheScope describes what translates units with provider and model:
+// the pair and the generation settings sent with every request
+func unitCacheScope(provider, model string, generation config.GenerationOptions) string {
+	settings, _ := json.Marshal(generation)
+	return provider + "/" + model + "\x00" + string(settings)
+}
+
+// referencedSignatures returns the signatures of the definitions in units,
+// other than unit itself, whose names appear in unit, one per line
+func referencedSignatures(unit Unit, units []Unit) string {
+	var signatures []string
+	for _, other := range units {
+		if other.Kind != UnitDefinition || other.Name == unit.Name {
+			continue
+		}
+		if regexp.MustCompile(`\b` + regexp.QuoteMeta(other.Name) + `\b`).MatchString(unit.Text) {
+			signatures = append(signatures, other.Signature)
+		}
+	}
+	return strings.Join(signatures, "\n")
+}
+
+// IncrementalResult reports how many units were served from the cache
+type IncrementalResult struct {
+	Code       string
+	Units      int
+	Reused     int
+	Translated int
+}
+
+// translateIncremental splits input into top-level units and translates only
+// the units whose content, or the signatures they refer to, changed since
+// they were last cached. The Python of unchanged units is passed to the model
+// as fixed context. New translations are cached under scope, unless cacheable
+// reports that they did not all come from the model scope names, as when a
+// fallback model answered.
+func translateIncremental(ctx context.Context, generate generateFunc, input, scope string, cache *UnitCache, cacheable func() bool) (*IncrementalResult, error) {
+	units := SplitTopLevel(input)
+	if len(units) == 0 {
+		return nil, fmt.Errorf("no pseudocode to translate")
+	}
+
+	summary := InterfaceSummary(units)
+	code := make([]string, len(units))
+	keys := make([]string, len(units))
+	var changed []int
+	var fixed []string
+
+	for i, unit := range units {
+		keys[i] = UnitCacheKey(scope, referencedSignatures(unit, units), unit)
+		if cached, ok := cache.Get(keys[i]); ok {
+			code[i] = cached
+			fixed = append(fixed, cached)
+			continue
+		}
+		changed = append(changed, i)
+	}
+
+	result := &IncrementalResult{
+		Units:      len(units),
+		Reused:     len(units) - len(changed),
+		Translated: len(changed),
+	}
+
+	if len(changed) > 0 {
+		fixedContext := strings.TrimSpace(AssembleModule(fixed))
+
+		_, span := logging.Start(ctx, "prompt.build", "prompts", len(changed))
+		prompts := make([]string, len(changed))
+		for i, index := range changed {
+			prompts[i] = BuildIncrementalPrompt(units[index].Text, summary, fixedContext)
+		}
+		span.End(nil)
+
+		translated, err := translateAll(ctx, generate, prompts, "changed unit")
+		if err != nil {
+			return nil, err
+		}
+
+		store := cacheable()
+		for i, index := range changed {
+			code[index] = translated[i]
+			if !store {
+				continue
+			}
+			if err := cache.Put(keys[index], translated[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result.Code = AssembleModule(code)
+	return result, nil
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/username/pseudolang/internal/config"
+)
+
+// fakeUnitTranslator turns "function name()" units into "def name(): pass"
+// and records which units it was asked to translate
+type fakeUnitTranslator struct {
+	mu        sync.Mutex
+	requested []string
+	prompts   []string
+}
+
+var fakeUnitNameRe = regexp.MustCompile(`(?s)<pseudocode>\s*function (\w+)\((.*?)\)`)
+
+func (f *fakeUnitTranslator) generate(ctx context.Context, prompt string) (string, error) {
+	match := fakeUnitNameRe.FindStringSubmatch(prompt)
+
+	f.mu.Lock()
+	f.requested = append(f.requested, match[1])
+	f.prompts = append(f.prompts, prompt)
+	f.mu.Unlock()
+
+	return "<code>\ndef " + match[1] + "(" + match[2] + "):\n    pass\n</code>", nil
+}
+
+func TestTranslateIncremental(t *testing.T) {
+	cache := NewUnitCache(t.TempDir())
+	translator := &fakeUnitTranslator{}
+
+	original := "function a()\n  return 1\n\nfunction b()\n  return 2"
+	result, err := translateIncremental(context.Background(), translator.generate, original, "gpt-4", cache, alwaysCacheable)
+	if err != nil {
+		t.Fatalf("translateIncremental() unexpected error = %v", err)
+	}
+	if result.Reused != 0 || result.Translated != 2 {
+		t.Errorf("first run reused %d and translated %d, want 0 and 2", result.Reused, result.Translated)
+	}
+
+	want := "def a():\n    pass\n\n\ndef b():\n    pass\n"
+	if result.Code != want {
+		t.Errorf("translateIncremental() code = %q, want %q", result.Code, want)
+	}
+
+	translator.requested, translator.prompts = nil, nil
+	edited := "function a()\n  return 1\n\nfunction b()\n  return 3"
+	result, err = translateIncremental(context.Background(), translator.generate, edited, "gpt-4", cache, alwaysCacheable)
+	if err != nil {
+		t.Fatalf("translateIncremental() unexpected error = %v", err)
+	}
+	if result.Reused != 1 || result.Translated != 1 {
+		t.Errorf("second run reused %d and translated %d, want 1 and 1", result.Reused, result.Translated)
+	}
+	if len(translator.requested) != 1 || translator.requested[0] != "b" {
+		t.Errorf("second run translated %v, want only [b]", translator.requested)
+	}
+	if !strings.Contains(translator.prompts[0], "<fixed_python>\ndef a():\n    pass\n</fixed_python>") {
+		t.Errorf("changed unit prompt does not carry the unchanged Python as fixed context")
+	}
+
+	if result.Code != want {
+		t.Errorf("translateIncremental() code = %q, want %q", result.Code, want)
+	}
+
+	// A changed signature re-translates the units that refer to it, and
+	// leaves the others alone
+	original = "function a()\n  return 1\n\nfunction b()\n  return 2\n\nfunction c()\n  return b()"
+	if _, err := translateIncremental(context.Background(), translator.generate, original, "gpt-4", cache, alwaysCacheable); err != nil {
+		t.Fatalf("translateIncremental() unexpected error = %v", err)
+	}
+	translator.requested, translator.prompts = nil, nil
+	edited = "function a()\n  return 1\n\nfunction b(x)\n  return x\n\nfunction c()\n  return b(1)"
+	result, err = translateIncremental(context.Background(), translator.generate, edited, "gpt-4", cache, alwaysCacheable)
+	if err != nil {
+		t.Fatalf("translateIncremental() unexpected error = %v", err)
+	}
+	slices.Sort(translator.requested)
+	if result.Reused != 1 || !slices.Equal(translator.requested, []string{"b", "c"}) {
+		t.Errorf("run after a signature change reused %d and translated %v, want a reused and b and c translated", result.Reused, translator.requested)
+	}
+
+	// Adding a definition leaves the units that do not refer to it alone
+	translator.requested, translator.prompts = nil, nil
+	result, err = translateIncremental(context.Background(), translator.generate, edited+"\n\nfunction d()\n  return 4", "gpt-4", cache, alwaysCacheable)
+	if err != nil {
+		t.Fatalf("translateIncremental() unexpected error = %v", err)
+	}
+	if result.Reused != 3 || len(translator.requested) != 1 || translator.requested[0] != "d" {
+		t.Errorf("run after adding a definition reused %d and translated %v, want 3 and [d]", result.Reused, translator.requested)
+	}
+}
+
+func alwaysCacheable() bool { return true }
+
+func TestTranslateIncrementalSkipsCachingFallbackOutput(t *testing.T) {
+	cache := NewUnitCache(t.TempDir())
+	translator := &fakeUnitTranslator{}
+	input := "function a()\n  return 1"
+
+	// As when a fallback model answered
+	notCacheable := func() bool { return false }
+	for run := 1; run <= 2; run++ {
+		result, err := translateIncremental(context.Background(), translator.generate, input, "gpt-4", cache, notCacheable)
+		if err != nil {
+			t.Fatalf("translateIncremental() unexpected error = %v", err)
+		}
+		if result.Translated != 1 {
+			t.Errorf("run %d translated %d units, want 1 since nothing was cached", run, result.Translated)
+		}
+	}
+}
+
+func TestUnitCacheKey(t *testing.T) {
+	unit := Unit{Text: "function a()\n  return b()"}
+	signatures := "function b()"
+
+	if UnitCacheKey("gpt-4", signatures, unit) != UnitCacheKey("gpt-4", signatures, unit) {
+		t.Errorf("UnitCacheKey() is not deterministic")
+	}
+	if UnitCacheKey("gpt-4", signatures, unit) == UnitCacheKey("claude-3-opus", signatures, unit) {
+		t.Errorf("UnitCacheKey() does not depend on the scope")
+	}
+	if UnitCacheKey("gpt-4", signatures, unit) == UnitCacheKey("gpt-4", signatures, Unit{Text: "function a(x)"}) {
+		t.Errorf("UnitCacheKey() does not depend on the unit text")
+	}
+	if UnitCacheKey("gpt-4", signatures, unit) == UnitCacheKey("gpt-4", "function b(x)", unit) {
+		t.Errorf("UnitCacheKey() does not depend on the referenced signatures")
+	}
+}
+
+func TestUnitCacheScope(t *testing.T) {
+	cold, warm := 0.0, 0.7
+	scope := unitCacheScope("openai", "gpt-4o", config.GenerationOptions{Temperature: &cold})
+
+	if scope == unitCacheScope("azure", "gpt-4o", config.GenerationOptions{Temperature: &cold}) {
+		t.Errorf("unitCacheScope() does not depend on the provider")
+	}
+	if scope == unitCacheScope("openai", "gpt-4o", config.GenerationOptions{Temperature: &warm}) {
+		t.Errorf("unitCacheScope() does not depend on the temperature")
+	}
+	if scope == unitCacheScope("openai", "gpt-4o", config.GenerationOptions{Temperature: &cold, SystemPrompt: "Be terse"}) {
+		t.Errorf("unitCacheScope() does not depend on the system prompt")
+	}
+}
+
+func TestReferencedSignatures(t *testing.T) {
+	units := SplitTopLevel("function add(x, y)\n  return x + y\n\nfunction addAll(xs)\n  return fold(xs, add)\n\nprint addAll([1])")
+
+	if got := referencedSignatures(units[0], units); got != "" {
+		t.Errorf("referencedSignatures(add) = %q, want none, as addAll only contains its name", got)
+	}
+	if got := referencedSignatures(units[1], units); got != "function add(x, y)" {
+		t.Errorf("referencedSignatures(addAll) = %q, want add's signature", got)
+	}
+	if got := referencedSignatures(units[2], units); got != "function addAll(xs)" {
+		t.Errorf("referencedSignatures(statements) = %q, want addAll's signature", got)
+	}
+}
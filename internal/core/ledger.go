@@ -0,0 +1,198 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/username/pseudolang/internal/config"
+)
+
+// LedgerName is the file name of the usage ledger in the state directory
+const LedgerName = "usage.jsonl"
+
+// Ways usage can be grouped by SummarizeUsage
+const (
+	UsageByDay     = "day"
+	UsageByModel   = "model"
+	UsageByProject = "project"
+)
+
+// LedgerEntry records what one model used during one run
+type LedgerEntry struct {
+	Time             time.Time `json:"time"`
+	Project          string    `json:"project,omitempty"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	Requests         int       `json:"requests"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Estimated        bool      `json:"estimated,omitempty"`
+	// Cost is in US dollars; nil when the model's prices are unknown
+	Cost *float64 `json:"cost,omitempty"`
+}
+
+// Ledger is an append-only record of usage, one JSON entry per line
+type Ledger struct {
+	path string
+}
+
+// NewLedger returns the ledger stored at path
+func NewLedger(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// DefaultLedger returns the ledger in the state directory
+func DefaultLedger() (*Ledger, error) {
+	dir, err := config.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewLedger(filepath.Join(dir, LedgerName)), nil
+}
+
+// Path returns the file the ledger is stored in
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Append adds entries to the ledger. They are written with a single append
+// so that concurrent runs cannot interleave their lines.
+func (l *Ledger) Append(entries []LedgerEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries returns the entries recorded at or after since. Lines that cannot
+// be parsed, such as one cut short by a crash, are skipped.
+func (l *Ledger) Entries(since time.Time) ([]LedgerEntry, error) {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []LedgerEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry LedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if !entry.Time.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// ledgerEntries converts the usage of a run into ledger entries
+func ledgerEntries(now time.Time, project string, models []ModelUsage) []LedgerEntry {
+	entries := make([]LedgerEntry, 0, len(models))
+	for _, m := range models {
+		entry := LedgerEntry{
+			Time:             now,
+			Project:          project,
+			Provider:         m.Provider,
+			Model:            m.Model,
+			Requests:         m.Requests,
+			PromptTokens:     m.PromptTokens,
+			CompletionTokens: m.CompletionTokens,
+			Estimated:        m.Estimated,
+		}
+		if m.CostKnown {
+			cost := m.Cost
+			entry.Cost = &cost
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// UsageTotal is the usage of one group of ledger entries
+type UsageTotal struct {
+	Key string
+	// Runs counts each model used by a run separately
+	Runs             int
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	// Cost is in US dollars and leaves out entries whose cost is unknown,
+	// which are counted in UnpricedRuns
+	Cost         float64
+	UnpricedRuns int
+}
+
+// SummarizeUsage totals entries grouped by day (in local time), model or
+// project, sorted by key
+func SummarizeUsage(entries []LedgerEntry, by string) ([]UsageTotal, error) {
+	var key func(LedgerEntry) string
+	switch by {
+	case UsageByDay:
+		key = func(e LedgerEntry) string { return e.Time.Local().Format(time.DateOnly) }
+	case UsageByModel:
+		key = func(e LedgerEntry) string { return e.Provider + "/" + e.Model }
+	case UsageByProject:
+		key = func(e LedgerEntry) string { return e.Project }
+	default:
+		return nil, fmt.Errorf("unknown grouping %q (expected %s, %s or %s)", by, UsageByDay, UsageByModel, UsageByProject)
+	}
+
+	totals := map[string]*UsageTotal{}
+	for _, entry := range entries {
+		k := key(entry)
+		total, ok := totals[k]
+		if !ok {
+			total = &UsageTotal{Key: k}
+			totals[k] = total
+		}
+
+		total.Runs++
+		total.Requests += entry.Requests
+		total.PromptTokens += entry.PromptTokens
+		total.CompletionTokens += entry.CompletionTokens
+		if entry.Cost != nil {
+			total.Cost += *entry.Cost
+		} else {
+			total.UnpricedRuns++
+		}
+	}
+
+	summary := make([]UsageTotal, 0, len(totals))
+	for _, total := range totals {
+		summary = append(summary, *total)
+	}
+	sort.Slice(summary, func(i, j int) bool { return summary[i].Key < summary[j].Key })
+	return summary, nil
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLedger_AppendEntries(t *testing.T) {
+	ledger := NewLedger(filepath.Join(t.TempDir(), "state", LedgerName))
+
+	if entries, err := ledger.Entries(time.Time{}); err != nil || len(entries) != 0 {
+		t.Fatalf("Entries() on a missing ledger = %v, %v, want none", entries, err)
+	}
+
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.Local)
+	first := ledgerEntries(day, "demo", []ModelUsage{
+		{Provider: "openai", Model: "gpt-4o", Requests: 2, Usage: Usage{PromptTokens: 100, CompletionTokens: 50}, Cost: 0.25, CostKnown: true},
+		{Provider: "ollama", Model: "qwen2.5-coder", Requests: 1, Usage: Usage{PromptTokens: 10, CompletionTokens: 5, Estimated: true}},
+	})
+	second := ledgerEntries(day.AddDate(0, 0, 1), "", []ModelUsage{
+		{Provider: "openai", Model: "gpt-4o", Requests: 1, Usage: Usage{PromptTokens: 40, CompletionTokens: 20}, Cost: 0.5, CostKnown: true},
+	})
+	if err := ledger.Append(first); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if err := ledger.Append(second); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	// A line cut short by a crash is skipped rather than failing the read
+	f, err := os.OpenFile(ledger.Path(), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2025-03-02T`)
+	f.Close()
+
+	entries, err := ledger.Entries(time.Time{})
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Entries() returned %d entries, want 3", len(entries))
+	}
+	if entries[1].Cost != nil || !entries[1].Estimated {
+		t.Errorf("unpriced entry = %+v, want no cost and estimated", entries[1])
+	}
+
+	if recent, _ := ledger.Entries(day.AddDate(0, 0, 1)); len(recent) != 1 {
+		t.Errorf("Entries(since the second day) returned %d entries, want 1", len(recent))
+	}
+}
+
+func TestSummarizeUsage(t *testing.T) {
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.Local)
+	cost := func(dollars float64) *float64 { return &dollars }
+	entries := []LedgerEntry{
+		{Time: day, Project: "demo", Provider: "openai", Model: "gpt-4o", Requests: 2, PromptTokens: 100, CompletionTokens: 50, Cost: cost(0.25)},
+		{Time: day, Project: "demo", Provider: "ollama", Model: "qwen2.5-coder", Requests: 1, PromptTokens: 10, CompletionTokens: 5},
+		{Time: day.AddDate(0, 0, 1), Provider: "openai", Model: "gpt-4o", Requests: 1, PromptTokens: 40, CompletionTokens: 20, Cost: cost(0.5)},
+	}
+
+	byDay, err := SummarizeUsage(entries, UsageByDay)
+	if err != nil {
+		t.Fatalf("SummarizeUsage(day) error = %v", err)
+	}
+	if len(byDay) != 2 || byDay[0].Key != "2025-03-01" || byDay[0].Runs != 2 || byDay[0].Cost != 0.25 || byDay[0].UnpricedRuns != 1 {
+		t.Errorf("SummarizeUsage(day) = %+v, want 2025-03-01 with 2 runs, $0.25 and 1 unpriced run first", byDay)
+	}
+
+	byModel, _ := SummarizeUsage(entries, UsageByModel)
+	if len(byModel) != 2 || byModel[1].Key != "openai/gpt-4o" || byModel[1].Requests != 3 || byModel[1].PromptTokens != 140 || byModel[1].Cost != 0.75 {
+		t.Errorf("SummarizeUsage(model) = %+v, want openai/gpt-4o with 3 requests, 140 prompt tokens and $0.75", byModel)
+	}
+
+	byProject, _ := SummarizeUsage(entries, UsageByProject)
+	if len(byProject) != 2 || byProject[0].Key != "" || byProject[1].Key != "demo" {
+		t.Errorf("SummarizeUsage(project) = %+v, want no project then demo", byProject)
+	}
+
+	if _, err := SummarizeUsage(entries, "week"); err == nil {
+		t.Errorf("SummarizeUsage(week) succeeded, want an error")
+	}
+}
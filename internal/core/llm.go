@@ -3,60 +3,319 @@ package core
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/teilomillet/gollm"
 	"github.com/username/pseudolang/internal/config"
+	"github.com/username/pseudolang/internal/logging"
 )
 
-func ExecuteWithLLM(ctx context.Context, input string, verbose bool) error {
-	cfg, err := config.Load()
+// ExecuteOptions controls how pseudocode is translated and run
+type ExecuteOptions struct {
+	// Verbose prints the generated Python code before execution
+	Verbose bool
+	// Chunked translates the input in pieces split at top-level definitions
+	Chunked bool
+	// ChunkTokens is the target size of one chunk; DefaultChunkTokens when zero
+	ChunkTokens int
+	// Incremental reuses cached translations of top-level units that have
+	// not changed and only re-translates the rest
+	Incremental bool
+	// Overrides are the project and command-line config layers
+	Overrides config.Overrides
+	// Run controls how the generated Python is run
+	Run RunOptions
+	// Stats prints the tokens used and their cost after translating
+	Stats bool
+	// Project is the name the run's usage is recorded under in the ledger
+	Project string
+	// MaxCost, when set, replaces the per-run budget in US dollars
+	MaxCost *float64
+	// ShowAnalysis prints the model's conversion analysis as it streams in
+	ShowAnalysis bool
+	// Source is the file the pseudocode came from, kept in the run history
+	Source string
+	// JSON prints one JSON document describing the run to stdout, in place
+	// of the program's output and the verbose output
+	JSON bool
+	// Record, when set, receives the model, usage and assumptions of the
+	// translation. ExecuteWithLLM and ExecuteProgram set it to the record of
+	// the run.
+	Record *RunRecord
+	// DumpDir, when set, receives the full prompt and raw response of every
+	// model request
+	DumpDir string
+}
+
+// chunkTokens returns the target size of one chunk
+func (o ExecuteOptions) chunkTokens() int {
+	if o.ChunkTokens <= 0 {
+		return DefaultChunkTokens
+	}
+	return o.ChunkTokens
+}
+
+// ExecuteWithLLM translates input to Python and runs it, recording the run
+// in the history
+func ExecuteWithLLM(ctx context.Context, input string, opts ExecuteOptions) error {
+	record := newRunRecord(opts, input)
+	translate := func(opts ExecuteOptions) error {
+		pythonCode, err := TranslateWithLLM(ctx, input, opts)
+		if err != nil {
+			return err
+		}
+		record.Code = pythonCode
+
+		if opts.Verbose {
+			fmt.Println("--- Generated Python Code ---")
+			fmt.Println(pythonCode)
+			fmt.Println("--- End Generated Python Code ---")
+			fmt.Println()
+		}
+		return nil
+	}
+
+	return record.run(opts, translate, func(run RunOptions) error {
+		return ExecutePythonCode(ctx, record.Code, run)
+	})
+}
+
+// TranslateWithLLM converts pseudocode to Python using the active model
+func TranslateWithLLM(ctx context.Context, input string, opts ExecuteOptions) (code string, err error) {
+	ctx, span := logging.Start(ctx, "translation", "input_bytes", len(input))
+	defer func() { span.End(err) }()
+
+	live := newLiveOutput(os.Stderr, opts.ShowAnalysis)
+	chain, cfg, err := newGenerator(ctx, opts, live)
+	if err != nil {
+		return "", err
+	}
+	if err := chain.preflight(cfg, planRequests(input, opts)); err != nil {
+		return "", err
+	}
+	if ctx, err = withDump(ctx, opts.DumpDir); err != nil {
+		return "", err
+	}
+	if !opts.Chunked && !opts.Incremental {
+		if tokens := CountTokens(input); tokens > LargeInputTokens {
+			fmt.Fprintf(os.Stderr, "Warning: the input is about %d tokens; --chunked translates large inputs in pieces\n", tokens)
+		}
+	}
+
+	code, err = translateInput(live.start(ctx), chain, cfg, input, opts)
+	live.finish()
+	finishRun(chain, opts, err)
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// finishRun reports which model produced the code and records what the run
+// used. Usage is recorded even when translation failed, since the requests
+// that succeeded are still billed.
+func finishRun(chain *modelChain, opts ExecuteOptions, err error) {
+	if err == nil {
+		chain.report(os.Stderr, opts.Verbose)
+	}
+
+	usage := chain.meter.Models()
+	if opts.Record != nil {
+		opts.Record.translated(chain, usage)
+	}
+	if opts.Stats {
+		printStats(os.Stderr, usage)
+	}
+
+	ledger, ledgerErr := DefaultLedger()
+	if ledgerErr == nil {
+		ledgerErr = ledger.Append(ledgerEntries(time.Now(), opts.Project, usage))
+	}
+	if ledgerErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record usage: %v\n", ledgerErr)
+	}
+}
+
+// translateInput converts input to Python with chain, chunked or
+// incrementally as opts ask
+func translateInput(ctx context.Context, chain *modelChain, cfg *config.Resolved, input string, opts ExecuteOptions) (string, error) {
+	generate := chain.generate
+
+	if opts.Incremental {
+		cacheDir, err := config.CacheDir()
+		if err != nil {
+			return "", err
+		}
+
+		cache := NewUnitCache(filepath.Join(cacheDir, "units"))
+		// Only the active model's translations are cached under its scope
+		scope := unitCacheScope(cfg.ActiveProvider, cfg.ActiveModel, cfg.GenerationFor(cfg.SettingsName()))
+		cacheable := func() bool {
+			_, fallback := chain.used()
+			return !fallback
+		}
+		result, err := translateIncremental(ctx, generate, input, scope, cache, cacheable)
+		if err != nil {
+			return "", err
+		}
+
+		if opts.Verbose {
+			fmt.Printf("Reused %d of %d units, translated %d\n", result.Reused, result.Units, result.Translated)
+		}
+		return result.Code, nil
+	}
+
+	if opts.Chunked {
+		return translateChunked(ctx, generate, input, opts.chunkTokens())
+	}
+
+	_, span := logging.Start(ctx, "prompt.build")
+	prompt := BuildPseudocodePrompt(input)
+	span.Add("prompt_bytes", len(prompt))
+	span.End(nil)
+
+	return translate(ctx, generate, prompt)
+}
+
+// translate sends one prompt and extracts the Python code from the response
+func translate(ctx context.Context, generate generateFunc, prompt string) (string, error) {
+	response, err := generateComplete(ctx, generate, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate response: %w", err)
+	}
+
+	_, span := logging.Start(ctx, "extraction", "response_bytes", len(response))
+	pythonCode, err := ExtractPythonCode(response)
+	span.End(err)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract Python code: %w", err)
+	}
+
+	return pythonCode, nil
+}
+
+// newGenerator resolves the layered config and connects to the active
+// model, with the configured fallback models behind it. Retries and
+// fallbacks are reported to notify.
+func newGenerator(ctx context.Context, opts ExecuteOptions, notify io.Writer) (*modelChain, *config.Resolved, error) {
+	_, span := logging.Start(ctx, "config.load")
+	if opts.Overrides.Profile != "" {
+		span.Add("profile", opts.Overrides.Profile)
+	}
+	if path, err := config.Path(); err == nil {
+		span.Add("path", path)
+	}
+	cfg, err := config.LoadResolved(opts.Overrides)
+	if err == nil {
+		span.Add("provider", cfg.ActiveProvider, "model", cfg.ActiveModel)
+	}
+	span.End(err)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
 	if cfg.ActiveModel == "" {
-		return fmt.Errorf("no active model configured. Use 'ps model <model>' to set one")
+		return nil, nil, fmt.Errorf("no active model configured. Use 'ps model <model>' to set one")
 	}
 
 	if cfg.ActiveProvider == "" {
-		return fmt.Errorf("no active provider configured")
+		return nil, nil, fmt.Errorf("no active provider configured")
+	}
+
+	policy, err := retryPolicy(cfg.Retry)
+	if err != nil {
+		return nil, nil, err
 	}
+	policy.Notify = notify
 
-	token, ok := cfg.GetToken(cfg.ActiveProvider)
-	if !ok || token == "" {
-		return fmt.Errorf("no API token configured for provider: %s", cfg.ActiveProvider)
+	// The active model is connected now so that its errors surface before
+	// any work is done; fallbacks are connected only if they are needed
+	meter := &UsageMeter{cost: cfg.Cost}
+	generate, err := connectModel(cfg, meter, cfg.ActiveProvider, cfg.ActiveModel, cfg.SettingsName())
+	if err != nil {
+		return nil, nil, err
 	}
 
-	llm, err := gollm.NewLLM(
-		gollm.SetProvider(cfg.ActiveProvider),
-		gollm.SetModel(cfg.ActiveModel),
-		gollm.SetAPIKey(token),
-		gollm.SetMaxTokens(10000),
-	)
+	ledger, err := DefaultLedger()
 	if err != nil {
-		return fmt.Errorf("failed to initialize LLM: %w", err)
+		return nil, nil, err
+	}
+	budgets, err := loadBudgets(cfg.Budget, opts.MaxCost, ledger, time.Now())
+	if err != nil {
+		return nil, nil, err
 	}
 
-	promptText := BuildPseudocodePrompt(input)
+	chain := &modelChain{policy: policy, notify: notify, meter: meter, budgets: budgets}
+	chain.models = append(chain.models, &chainModel{provider: cfg.ActiveProvider, model: cfg.ActiveModel, settings: cfg.SettingsName(), generate: generate})
 
-	prompt := gollm.NewPrompt(promptText)
+	for _, name := range cfg.Fallbacks {
+		route, err := cfg.RouteModel(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid fallback model: %w", err)
+		}
+		chain.models = append(chain.models, &chainModel{
+			provider: route.Provider,
+			model:    route.Model,
+			settings: route.SettingsName(),
+			connect: func() (generateFunc, error) {
+				return connectModel(cfg, meter, route.Provider, route.Model, route.SettingsName())
+			},
+		})
+	}
 
-	response, err := llm.Generate(ctx, prompt)
+	return chain, cfg, nil
+}
+
+// connectModel connects to model at provider, applying the generation
+// settings kept under settingsName and recording usage in meter
+func connectModel(cfg *config.Resolved, meter *UsageMeter, provider, model, settingsName string) (generateFunc, error) {
+	kind, err := cfg.ProviderType(provider)
 	if err != nil {
-		return fmt.Errorf("failed to generate response: %w", err)
+		return nil, err
 	}
 
-	pythonCode, err := ExtractPythonCode(response)
-	if err != nil {
-		return fmt.Errorf("failed to extract Python code: %w", err)
+	// A missing token is fine for local servers, but a configured source
+	// that fails is always an error
+	token, err := cfg.ResolveToken(provider)
+	if err != nil && (ProviderNeedsToken(kind) || cfg.Providers[provider].TokenSource() != "none") {
+		return nil, err
 	}
+	logging.AddSecret(token)
 
-	if verbose {
-		fmt.Println("--- Generated Python Code ---")
-		fmt.Println(pythonCode)
-		fmt.Println("--- End Generated Python Code ---")
-		fmt.Println()
+	// Retries are handled by the model chain, which can see the status code
+	// and reports failures itself
+	llmOptions := []gollm.ConfigOption{
+		gollm.SetModel(model),
+		gollm.SetMaxTokens(cfg.MaxTokens(settingsName)),
+		gollm.SetMaxRetries(0),
+		gollm.SetLogLevel(gollm.LogLevelOff),
+	}
+
+	generation := cfg.GenerationFor(settingsName)
+	if generation.Temperature != nil {
+		llmOptions = append(llmOptions, gollm.SetTemperature(*generation.Temperature))
+	}
+	if generation.TopP != nil {
+		llmOptions = append(llmOptions, gollm.SetTopP(*generation.TopP))
+	}
+	// Models known not to take a seed would reject the request
+	info, _ := cfg.ModelInfo(model)
+	if generation.Seed != nil && (info.Capabilities == nil || info.Supports(config.CapabilitySeed)) {
+		llmOptions = append(llmOptions, gollm.SetSeed(*generation.Seed))
+	}
+
+	var promptOptions []gollm.PromptOption
+	if generation.SystemPrompt != "" {
+		promptOptions = append(promptOptions, gollm.WithSystemPrompt(generation.SystemPrompt, ""))
+	}
+
+	generate, err := newLLM(provider, kind, cfg.Providers[provider], token, llmOptions, promptOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize LLM: %w", err)
 	}
 
-	return ExecutePythonCode(ctx, pythonCode)
+	return withinContextWindow(observed(generate, provider, model, meter), provider, model, info.ContextWindow), nil
 }
@@ -0,0 +1,336 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/username/pseudolang/internal/logging"
+)
+
+// useDirectiveRe matches a line such as `use "lib/sorting.pseudo"`
+var useDirectiveRe = regexp.MustCompile(`^\s*use\s+"([^"]+)"\s*;?\s*$`)
+
+var moduleNameRe = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// SourceFile is one pseudocode file of a multi-file program
+type SourceFile struct {
+	// Path is the absolute path of the file
+	Path string
+	// Name is the path relative to the entry file's directory
+	Name string
+	// Module is the Python module name the file is translated to
+	Module string
+	// Source is the pseudocode with its use directives removed
+	Source string
+	// Imports are the absolute paths of the files this file uses
+	Imports []string
+}
+
+// Program is an entry file and every file it transitively uses
+type Program struct {
+	Entry *SourceFile
+	// Files are in dependency order: every file comes after the files it uses
+	// and the entry file is last
+	Files []*SourceFile
+}
+
+// File returns the file at the absolute path
+func (p *Program) File(path string) *SourceFile {
+	for _, file := range p.Files {
+		if file.Path == path {
+			return file
+		}
+	}
+	return nil
+}
+
+// LoadProgram reads entryPath and follows its use directives, resolving each
+// path relative to the file that contains it, or else to the first of
+// sourceDirs that has it. Import cycles are an error.
+func LoadProgram(entryPath string, sourceDirs ...string) (*Program, error) {
+	entryPath, err := filepath.Abs(entryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	loader := &programLoader{
+		root:       filepath.Dir(entryPath),
+		sourceDirs: sourceDirs,
+		state:      make(map[string]loadState),
+		modules:    make(map[string]bool),
+	}
+	if err := loader.load(entryPath, nil); err != nil {
+		return nil, err
+	}
+
+	program := &Program{Files: loader.files}
+	program.Entry = program.File(entryPath)
+	return program, nil
+}
+
+type loadState int
+
+const (
+	loading loadState = iota + 1
+	loaded
+)
+
+type programLoader struct {
+	root       string
+	sourceDirs []string
+	state      map[string]loadState
+	modules    map[string]bool
+	files      []*SourceFile
+}
+
+func (l *programLoader) load(path string, stack []string) error {
+	switch l.state[path] {
+	case loaded:
+		return nil
+	case loading:
+		return fmt.Errorf("import cycle: %s", l.describeCycle(append(stack, path)))
+	}
+	l.state[path] = loading
+	stack = append(stack, path)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if len(stack) > 1 {
+			return fmt.Errorf("failed to read %s (used by %s): %w", l.rel(path), l.rel(stack[len(stack)-2]), err)
+		}
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	source, uses := parseUseDirectives(string(content))
+
+	file := &SourceFile{
+		Path:   path,
+		Name:   l.rel(path),
+		Module: l.moduleName(path),
+		Source: source,
+	}
+
+	for _, use := range uses {
+		imported := l.resolve(use, path)
+
+		if slices.Contains(file.Imports, imported) {
+			continue
+		}
+
+		if err := l.load(imported, stack); err != nil {
+			return err
+		}
+		file.Imports = append(file.Imports, imported)
+	}
+
+	l.state[path] = loaded
+	l.files = append(l.files, file)
+	return nil
+}
+
+// resolve returns the file a use directive in from refers to: the path
+// relative to from's directory, or else to the first source directory that
+// has it. A file found nowhere resolves next to from, so that reading it
+// reports the path the user most likely meant.
+func (l *programLoader) resolve(use, from string) string {
+	if filepath.IsAbs(use) {
+		return filepath.Clean(use)
+	}
+
+	local := filepath.Join(filepath.Dir(from), use)
+	if _, err := os.Stat(local); err == nil {
+		return local
+	}
+	for _, dir := range l.sourceDirs {
+		candidate := filepath.Join(dir, use)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return local
+}
+
+// describeCycle renders the part of the stack that forms the cycle
+func (l *programLoader) describeCycle(stack []string) string {
+	last := stack[len(stack)-1]
+	start := 0
+	for i, path := range stack[:len(stack)-1] {
+		if path == last {
+			start = i
+			break
+		}
+	}
+
+	names := make([]string, 0, len(stack)-start)
+	for _, path := range stack[start:] {
+		names = append(names, l.rel(path))
+	}
+	return strings.Join(names, " -> ")
+}
+
+func (l *programLoader) rel(path string) string {
+	if rel, err := filepath.Rel(l.root, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// moduleName derives a unique Python identifier from the file's path
+// relative to the entry file's directory
+func (l *programLoader) moduleName(path string) string {
+	rel := strings.TrimSuffix(l.rel(path), filepath.Ext(path))
+	name := strings.Trim(moduleNameRe.ReplaceAllString(rel, "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "m_" + name
+	}
+
+	unique := name
+	for i := 2; l.modules[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	l.modules[unique] = true
+	return unique
+}
+
+// parseUseDirectives removes use directives from source and returns the
+// remaining pseudocode and the used paths in order
+func parseUseDirectives(source string) (string, []string) {
+	var kept []string
+	var uses []string
+
+	for _, line := range strings.Split(source, "\n") {
+		if match := useDirectiveRe.FindStringSubmatch(line); match != nil {
+			uses = append(uses, match[1])
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	return strings.Join(kept, "\n"), uses
+}
+
+// TranslateProgram converts every file of program to its own Python module
+// and returns the module sources keyed by module name. A single-file program
+// is translated with TranslateWithLLM so chunked and incremental modes apply.
+func TranslateProgram(ctx context.Context, program *Program, opts ExecuteOptions) (modules map[string]string, err error) {
+	if len(program.Files) == 1 {
+		code, err := TranslateWithLLM(ctx, program.Entry.Source, opts)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{program.Entry.Module: code}, nil
+	}
+
+	// Modules are translated whole, in one request each
+	if opts.Chunked || opts.Incremental {
+		return nil, fmt.Errorf("chunked and incremental translation only apply to single-file programs, and %s uses other files", program.Entry.Name)
+	}
+
+	ctx, span := logging.Start(ctx, "translation", "files", len(program.Files))
+	defer func() { span.End(err) }()
+
+	live := newLiveOutput(os.Stderr, opts.ShowAnalysis)
+	chain, cfg, err := newGenerator(ctx, opts, live)
+	if err != nil {
+		return nil, err
+	}
+
+	prompts := modulePrompts(program)
+	requests := make([]plannedRequest, len(program.Files))
+	for i, file := range program.Files {
+		requests[i] = plannedRequest{prompt: prompts[i], source: file.Source}
+	}
+	if err := chain.preflight(cfg, requests); err != nil {
+		return nil, err
+	}
+	if ctx, err = withDump(ctx, opts.DumpDir); err != nil {
+		return nil, err
+	}
+	modules, err = translateProgram(live.start(ctx), chain.generate, program)
+	live.finish()
+	finishRun(chain, opts, err)
+	if err != nil {
+		return nil, err
+	}
+	return modules, nil
+}
+
+// modulePrompts returns the prompt translating each file of program, in
+// file order
+func modulePrompts(program *Program) []string {
+	prompts := make([]string, len(program.Files))
+	for i, file := range program.Files {
+		var imports []ModuleInterface
+		for _, path := range file.Imports {
+			imported := program.File(path)
+			imports = append(imports, ModuleInterface{
+				Module:  imported.Module,
+				Summary: InterfaceSummary(SplitTopLevel(imported.Source)),
+			})
+		}
+		prompts[i] = BuildModulePrompt(file.Source, file.Name, file.Module, imports)
+	}
+	return prompts
+}
+
+func translateProgram(ctx context.Context, generate generateFunc, program *Program) (map[string]string, error) {
+	_, span := logging.Start(ctx, "prompt.build", "prompts", len(program.Files))
+	prompts := modulePrompts(program)
+	span.End(nil)
+
+	results, err := translateAll(ctx, generate, prompts, "file")
+	if err != nil {
+		return nil, err
+	}
+
+	modules := make(map[string]string, len(program.Files))
+	for i, file := range program.Files {
+		var header []string
+		for _, path := range file.Imports {
+			header = append(header, fmt.Sprintf("from .%s import *", program.File(path).Module))
+		}
+		modules[file.Module] = AssembleModule([]string{strings.Join(header, "\n"), results[i]})
+	}
+
+	return modules, nil
+}
+
+// ExecuteProgram translates every file of program and runs the entry module
+func ExecuteProgram(ctx context.Context, program *Program, opts ExecuteOptions) error {
+	if len(program.Files) == 1 {
+		return ExecuteWithLLM(ctx, program.Entry.Source, opts)
+	}
+
+	sources := make([]string, 0, 2*len(program.Files))
+	for _, file := range program.Files {
+		sources = append(sources, file.Name, file.Source)
+	}
+	record := newRunRecord(opts, sources...)
+
+	translate := func(opts ExecuteOptions) error {
+		modules, err := TranslateProgram(ctx, program, opts)
+		if err != nil {
+			return err
+		}
+		record.Modules, record.Entry = modules, program.Entry.Module
+
+		if opts.Verbose {
+			for _, file := range program.Files {
+				fmt.Printf("--- Generated Python Module %s (%s) ---\n", file.Module, file.Name)
+				fmt.Println(modules[file.Module])
+				fmt.Printf("--- End Generated Python Module %s ---\n", file.Module)
+				fmt.Println()
+			}
+		}
+		return nil
+	}
+
+	return record.run(opts, translate, func(run RunOptions) error {
+		return ExecutePythonPackage(ctx, record.Modules, record.Entry, run)
+	})
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadProgram(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.pseudo":        "use \"lib/sorting.pseudo\"\nuse \"lib/math.pseudo\"\nprint sort([3, 1])",
+		"lib/sorting.pseudo": "use \"math.pseudo\"\nfunction sort(xs)\n  return xs",
+		"lib/math.pseudo":    "function square(x)\n  return x * x",
+	})
+
+	program, err := LoadProgram(filepath.Join(dir, "main.pseudo"))
+	if err != nil {
+		t.Fatalf("LoadProgram() unexpected error = %v", err)
+	}
+
+	var names, modules []string
+	for _, file := range program.Files {
+		names = append(names, file.Name)
+		modules = append(modules, file.Module)
+	}
+
+	wantNames := []string{filepath.Join("lib", "math.pseudo"), filepath.Join("lib", "sorting.pseudo"), "main.pseudo"}
+	if strings.Join(names, ",") != strings.Join(wantNames, ",") {
+		t.Errorf("LoadProgram() files = %v, want %v", names, wantNames)
+	}
+
+	wantModules := []string{"lib_math", "lib_sorting", "main"}
+	if strings.Join(modules, ",") != strings.Join(wantModules, ",") {
+		t.Errorf("LoadProgram() modules = %v, want %v", modules, wantModules)
+	}
+
+	if program.Entry.Module != "main" {
+		t.Errorf("LoadProgram() entry = %q, want main", program.Entry.Module)
+	}
+	if strings.Contains(program.Entry.Source, "use ") {
+		t.Errorf("LoadProgram() left use directives in source: %q", program.Entry.Source)
+	}
+	if len(program.Entry.Imports) != 2 {
+		t.Errorf("LoadProgram() entry imports = %v, want 2", program.Entry.Imports)
+	}
+}
+
+func TestLoadProgramErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		errContains string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"main.pseudo": "use \"a.pseudo\"",
+				"a.pseudo":    "use \"b.pseudo\"",
+				"b.pseudo":    "use \"a.pseudo\"",
+			},
+			errContains: "import cycle: a.pseudo -> b.pseudo -> a.pseudo",
+		},
+		{
+			name: "self import",
+			files: map[string]string{
+				"main.pseudo": "use \"main.pseudo\"",
+			},
+			errContains: "import cycle: main.pseudo -> main.pseudo",
+		},
+		{
+			name: "missing file",
+			files: map[string]string{
+				"main.pseudo": "use \"missing.pseudo\"",
+			},
+			errContains: "failed to read missing.pseudo (used by main.pseudo)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+
+			_, err := LoadProgram(filepath.Join(dir, "main.pseudo"))
+			if err == nil {
+				t.Fatalf("LoadProgram() expected error but got none")
+			}
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("LoadProgram() error = %v, want error containing %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func TestLoadProgram_SourceDirs(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"tests/sort_test.pseudo": "use \"lib/sorting.pseudo\"\nuse \"helpers.pseudo\"\nprint sort([3, 1])",
+		"tests/helpers.pseudo":   "function check(x)\n  return x",
+		"src/lib/sorting.pseudo": "function sort(xs)\n  return xs",
+		"src/helpers.pseudo":     "function unused()\n  return 0",
+	})
+
+	program, err := LoadProgram(filepath.Join(dir, "tests", "sort_test.pseudo"), filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatalf("LoadProgram() unexpected error = %v", err)
+	}
+
+	// A file next to the importing one wins over the source directories
+	want := []string{filepath.Join(dir, "src", "lib", "sorting.pseudo"), filepath.Join(dir, "tests", "helpers.pseudo")}
+	if !slices.Equal(program.Entry.Imports, want) {
+		t.Errorf("LoadProgram() entry imports = %v, want %v", program.Entry.Imports, want)
+	}
+
+	if _, err := LoadProgram(filepath.Join(dir, "tests", "sort_test.pseudo")); err == nil {
+		t.Errorf("LoadProgram() without source directories found lib/sorting.pseudo, want an error")
+	}
+}
+
+func TestTranslateProgram(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.pseudo":        "use \"lib/sorting.pseudo\"\nprint sort([3, 1])",
+		"lib/sorting.pseudo": "function sort(xs)\n  return xs",
+	})
+
+	program, err := LoadProgram(filepath.Join(dir, "main.pseudo"))
+	if err != nil {
+		t.Fatalf("LoadProgram() unexpected error = %v", err)
+	}
+
+	generate := func(ctx context.Context, prompt string) (string, error) {
+		if strings.Contains(prompt, "Python module `main`") {
+			if !strings.Contains(prompt, "<module name=\"lib_sorting\">\nfunction sort(xs)\n</module>") {
+				t.Errorf("entry prompt does not describe the imported module")
+			}
+			return "<code>\nprint(sort([3, 1]))\n</code>", nil
+		}
+		return "<code>\ndef sort(xs):\n    return sorted(xs)\n</code>", nil
+	}
+
+	modules, err := translateProgram(context.Background(), generate, program)
+	if err != nil {
+		t.Fatalf("translateProgram() unexpected error = %v", err)
+	}
+
+	want := "from .lib_sorting import *\n\n\nprint(sort([3, 1]))\n"
+	if modules["main"] != want {
+		t.Errorf("translateProgram() main = %q, want %q", modules["main"], want)
+	}
+
+	want = "def sort(xs):\n    return sorted(xs)\n"
+	if modules["lib_sorting"] != want {
+		t.Errorf("translateProgram() lib_sorting = %q, want %q", modules["lib_sorting"], want)
+	}
+}
+
+func TestTranslateProgram_RejectsChunkedAndIncremental(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.pseudo": "use \"lib.pseudo\"\nprint f()",
+		"lib.pseudo":  "function f()\n  return 1",
+	})
+	program, err := LoadProgram(filepath.Join(dir, "main.pseudo"))
+	if err != nil {
+		t.Fatalf("LoadProgram() unexpected error = %v", err)
+	}
+
+	for _, opts := range []ExecuteOptions{{Chunked: true}, {Incremental: true}} {
+		_, err := TranslateProgram(context.Background(), program, opts)
+		if err == nil || !strings.Contains(err.Error(), "only apply to single-file programs") {
+			t.Errorf("TranslateProgram(%+v) error = %v, want it rejected", opts, err)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package core
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -62,24 +64,143 @@ After your analysis, provide the converted Python code in <code> tags.
 ` + "```" + `
 `
 
-// ExtractPythonCode parses the LLM response and extracts the Python code from <code> tags
-func ExtractPythonCode(response string) (string, error) {
-	re := regexp.MustCompile(`(?s)<code>\s*(.*?)\s*</code>`)
-	matches := re.FindStringSubmatch(response)
+// PromptVersion identifies the prompt templates, so that generated code can
+// be traced to the prompts that produced it. It changes whenever a template
+// does.
+func PromptVersion() string {
+	hash := sha256.New()
+	for _, template := range []string{PseudocodeToPythonPrompt, ContinuationPrompt, ChunkInstructions, IncrementalInstructions, ModuleInstructions, ModuleImportsSection} {
+		hash.Write([]byte(template))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+// BuildPseudocodePrompt replaces the {{PSEUDOCODE}} placeholder with actual input
+func BuildPseudocodePrompt(pseudocode string) string {
+	return strings.Replace(PseudocodeToPythonPrompt, "{{PSEUDOCODE}}", pseudocode, 1)
+}
+
+const ContinuationPrompt = `{{PROMPT}}
+
+---
+
+Your previous response to the request above was cut off before it was complete. Here is everything you wrote so far:
+
+<partial_response>
+{{PARTIAL}}
+</partial_response>
+
+Continue the response exactly where it stopped. Output only the remaining text, starting with the next character after the cut-off point. Do not repeat any text that was already written and do not add any commentary.
+`
+
+// BuildContinuationPrompt asks the model to resume a truncated response
+func BuildContinuationPrompt(prompt, partial string) string {
+	return strings.NewReplacer("{{PROMPT}}", prompt, "{{PARTIAL}}", partial).Replace(ContinuationPrompt)
+}
+
+const ChunkInstructions = `## Partial Program
+
+The pseudocode above is part {{PART}} of {{TOTAL}} of a larger program that is being converted in pieces. The pieces are concatenated into a single Python module afterwards.
+
+These names are defined across the whole program:
+
+<interface>
+{{INTERFACE}}
+</interface>
+
+- Convert only the pseudocode given above
+- Assume every name in the interface exists in the same module, and do not redefine names that are not part of this piece
+- Keep the exact names and parameter order from the interface
+- Do not add example usage, tests or an ` + "`if __name__ == \"__main__\"`" + ` block unless the pseudocode contains top-level statements
+`
+
+// BuildChunkPrompt builds the prompt for one chunk of a program translated in pieces
+func BuildChunkPrompt(pseudocode, summary string, part, total int) string {
+	instructions := strings.NewReplacer(
+		"{{PART}}", strconv.Itoa(part),
+		"{{TOTAL}}", strconv.Itoa(total),
+		"{{INTERFACE}}", summary,
+	).Replace(ChunkInstructions)
+
+	return BuildPseudocodePrompt(pseudocode) + "\n" + instructions
+}
+
+const IncrementalInstructions = `## Incremental Update
+
+The pseudocode above is one top-level unit of a larger program. The rest of the program has already been converted and must not change.
+
+These names are defined across the whole program:
+
+<interface>
+{{INTERFACE}}
+</interface>
+
+This is the already converted Python for the unchanged parts of the program. Treat it as fixed context: call into it as needed, match its naming and style, but do not repeat, modify or redefine any of it.
 
-	if len(matches) < 2 {
-		return "", fmt.Errorf("no <code> tags found in response")
+<fixed_python>
+{{FIXED}}
+</fixed_python>
+
+- Convert only the pseudocode unit given above
+- Keep the exact names and parameter order from the interface
+- Do not add example usage, tests or an ` + "`if __name__ == \"__main__\"`" + ` block unless the unit itself is top-level statements
+`
+
+// BuildIncrementalPrompt builds the prompt for re-translating one changed unit
+// with the Python of the unchanged units as fixed context
+func BuildIncrementalPrompt(pseudocode, summary, fixed string) string {
+	if fixed == "" {
+		fixed = "# (nothing has been converted yet)"
 	}
 
-	code := strings.TrimSpace(matches[1])
-	if code == "" {
-		return "", fmt.Errorf("code block is empty")
+	instructions := strings.NewReplacer(
+		"{{INTERFACE}}", summary,
+		"{{FIXED}}", fixed,
+	).Replace(IncrementalInstructions)
+
+	return BuildPseudocodePrompt(pseudocode) + "\n" + instructions
+}
+
+const ModuleInstructions = `## Module
+
+The pseudocode above is the file ` + "`{{FILE}}`" + ` of a multi-file program. It is converted to the Python module ` + "`{{MODULE}}`" + ` in a package with the other files.
+
+{{IMPORTS}}
+- Do not write import statements for other files of the program; they are added automatically
+- Keep the exact names and parameter order of the imported definitions
+`
+
+// ModuleImportsSection lists what one imported module provides
+const ModuleImportsSection = `The module imports these other files of the program. Everything they define is available as if it were defined in this module:
+
+{{MODULES}}
+`
+
+// BuildModulePrompt builds the prompt for translating one file of a
+// multi-file program. imports maps each imported module name to the
+// interface summary of its definitions.
+func BuildModulePrompt(pseudocode, file, module string, imports []ModuleInterface) string {
+	importSection := "The module does not import any other files of the program.\n"
+	if len(imports) > 0 {
+		var modules []string
+		for _, imported := range imports {
+			modules = append(modules, fmt.Sprintf("<module name=\"%s\">\n%s\n</module>", imported.Module, imported.Summary))
+		}
+		importSection = strings.Replace(ModuleImportsSection, "{{MODULES}}", strings.Join(modules, "\n"), 1)
 	}
 
-	return code, nil
+	instructions := strings.NewReplacer(
+		"{{FILE}}", file,
+		"{{MODULE}}", module,
+		"{{IMPORTS}}", importSection,
+	).Replace(ModuleInstructions)
+
+	return BuildPseudocodePrompt(pseudocode) + "\n" + instructions
 }
 
-// BuildPseudocodePrompt replaces the {{PSEUDOCODE}} placeholder with actual input
-func BuildPseudocodePrompt(pseudocode string) string {
-	return strings.Replace(PseudocodeToPythonPrompt, "{{PSEUDOCODE}}", pseudocode, 1)
+// ModuleInterface is the name and interface summary of an imported module
+type ModuleInterface struct {
+	Module  string
+	Summary string
 }
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"slices"
 	"strings"
 	"testing"
 )
@@ -91,6 +92,139 @@ for i in range(10):
         print(i)`,
 			wantErr: false,
 		},
+		{
+			name: "nested code tags",
+			response: `<code>
+<code>
+print("nested")
+</code>
+</code>`,
+			wantCode: `print("nested")`,
+			wantErr:  false,
+		},
+		{
+			name: "code tags wrapping a fenced block",
+			response: `<code>
+` + "```" + `python
+print("wrapped")
+` + "```" + `
+</code>`,
+			wantCode: `print("wrapped")`,
+			wantErr:  false,
+		},
+		{
+			name: "code tags with attributes",
+			response: `<code lang="python">
+print("attrs")
+</code>`,
+			wantCode: `print("attrs")`,
+			wantErr:  false,
+		},
+		{
+			name: "empty first block falls through to next block",
+			response: `<code></code>
+<code>
+print("second")
+</code>`,
+			wantCode: `print("second")`,
+			wantErr:  false,
+		},
+		{
+			name: "python fenced block",
+			response: `Here is the code:
+
+` + "```" + `python
+x = [1, 2, 3]
+print(sum(x))
+` + "```" + ``,
+			wantCode: `x = [1, 2, 3]
+print(sum(x))`,
+			wantErr: false,
+		},
+		{
+			name: "unlabelled fenced block",
+			response: `` + "```" + `
+print("plain")
+` + "```" + ``,
+			wantCode: `print("plain")`,
+			wantErr:  false,
+		},
+		{
+			name: "python fence preferred over earlier unlabelled fence",
+			response: `` + "```" + `
+$ python main.py
+` + "```" + `
+
+` + "```" + `python
+print("python")
+` + "```" + ``,
+			wantCode: `print("python")`,
+			wantErr:  false,
+		},
+		{
+			name: "code tags preferred over fenced blocks",
+			response: `` + "```" + `python
+print("fence")
+` + "```" + `
+
+<code>
+print("tags")
+</code>`,
+			wantCode: `print("tags")`,
+			wantErr:  false,
+		},
+		{
+			name: "fenced snippets inside analysis are ignored",
+			response: `<conversion_analysis>
+` + "```" + `python
+print("snippet")
+` + "```" + `
+</conversion_analysis>
+
+` + "```" + `python
+print("program")
+` + "```" + ``,
+			wantCode: `print("program")`,
+			wantErr:  false,
+		},
+		{
+			name:     "json structured output",
+			response: `{"analysis": "simple", "code": "print(\"json\")\n"}`,
+			wantCode: `print("json")`,
+			wantErr:  false,
+		},
+		{
+			name: "json inside a json fence",
+			response: `` + "```" + `json
+{"code": "for i in range(3):\n    print(i)"}
+` + "```" + ``,
+			wantCode: `for i in range(3):
+    print(i)`,
+			wantErr: false,
+		},
+		{
+			name: "only non-python fences",
+			response: `` + "```" + `bash
+echo hi
+` + "```" + ``,
+			wantCode:    "",
+			wantErr:     true,
+			errContains: "no python fenced code blocks found (found: bash)",
+		},
+		{
+			name:        "unclosed code tag",
+			response:    "<code>\nprint(\"cut off\")",
+			wantCode:    "",
+			wantErr:     true,
+			errContains: "no <code> tags found",
+		},
+		{
+			name:        "reports every extractor failure",
+			response:    "I could not convert this.",
+			wantCode:    "",
+			wantErr:     true,
+			errContains: "<code> tags: no <code> tags found in response; fenced blocks: no fenced code blocks found; json: no JSON object found",
+		},
 	}
 
 	for _, tt := range tests {
@@ -120,6 +254,67 @@ for i in range(10):
 	}
 }
 
+func TestExtractAssumptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     []string
+	}{
+		{
+			name: "assumptions section",
+			response: `<conversion_analysis>
+1. Inputs and outputs:
+   - Reads a number from stdin
+2. **Assumptions**:
+   - The input is a non-negative integer
+   - "display" means print
+3. Libraries: none
+</conversion_analysis>
+<code>print(1)</code>`,
+			want: []string{"The input is a non-negative integer", `"display" means print`},
+		},
+		{
+			name: "markdown heading and inline assumption",
+			response: `<conversion_analysis>
+## Notes
+- We assume the list is sorted
+## Assumptions
+* Names are unique
+</conversion_analysis>`,
+			want: []string{"We assume the list is sorted", "Names are unique"},
+		},
+		{
+			name: "assumption after the heading's colon",
+			response: `<conversion_analysis>
+Assumptions: dates are in UTC
+Edge cases: we assume an empty list prints nothing
+</conversion_analysis>`,
+			want: []string{"dates are in UTC", "Edge cases: we assume an empty list prints nothing"},
+		},
+		{
+			name:     "no analysis",
+			response: "<code>print(1)</code>",
+			want:     nil,
+		},
+		{
+			name: "analysis without assumptions",
+			response: `<conversion_analysis>
+1. Inputs: none
+</conversion_analysis>`,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExtractAssumptions(tt.response)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ExtractAssumptions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestBuildPseudocodePrompt(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -176,3 +371,23 @@ func TestBuildPseudocodePromptReplacesOnlyOnce(t *testing.T) {
 		t.Errorf("BuildPseudocodePrompt() replaced pseudocode %d times, want 1", count)
 	}
 }
+
+func TestBuildChunkPrompt(t *testing.T) {
+	got := BuildChunkPrompt("print total(xs)", "function total(xs)", 2, 3)
+
+	for _, want := range []string{
+		"print total(xs)",
+		"part 2 of 3",
+		"<interface>\nfunction total(xs)\n</interface>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("BuildChunkPrompt() result does not contain %q", want)
+		}
+	}
+
+	for _, placeholder := range []string{"{{PSEUDOCODE}}", "{{PART}}", "{{TOTAL}}", "{{INTERFACE}}"} {
+		if strings.Contains(got, placeholder) {
+			t.Errorf("BuildChunkPrompt() still contains %s placeholder", placeholder)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/teilomillet/gollm"
+	gollmconfig "github.com/teilomillet/gollm/config"
+	gollmllm "github.com/teilomillet/gollm/llm"
+	"github.com/teilomillet/gollm/providers"
+	"github.com/teilomillet/gollm/utils"
+	"github.com/username/pseudolang/internal/config"
+)
+
+// defaultAzureAPIVersion is used for Azure OpenAI when no api_version is configured
+const defaultAzureAPIVersion = "2024-10-21"
+
+// noToken is sent to servers that do not need a token, since gollm requires one
+const noToken = "unused"
+
+// providerAPI describes how to reach one kind of provider
+type providerAPI struct {
+	// baseURL is the default API address; empty when it must be configured
+	baseURL string
+	// style is the request format: providers.TypeOpenAI or providers.TypeAnthropic
+	style      providers.ProviderType
+	authHeader string
+	authPrefix string
+	headers    map[string]string
+	// verifyPath is a cheap authenticated GET used by VerifyProvider
+	verifyPath string
+	// needsToken is false for servers that usually run without authentication
+	needsToken bool
+}
+
+var providerAPIs = map[string]providerAPI{
+	"openai": {
+		baseURL: "https://api.openai.com/v1", style: providers.TypeOpenAI,
+		authHeader: "Authorization", authPrefix: "Bearer ", verifyPath: "/models", needsToken: true,
+	},
+	"anthropic": {
+		baseURL: "https://api.anthropic.com", style: providers.TypeAnthropic,
+		authHeader: "x-api-key", headers: map[string]string{"anthropic-version": "2023-06-01"},
+		verifyPath: "/v1/models", needsToken: true,
+	},
+	"groq": {
+		baseURL: "https://api.groq.com/openai/v1", style: providers.TypeOpenAI,
+		authHeader: "Authorization", authPrefix: "Bearer ", verifyPath: "/models", needsToken: true,
+	},
+	"mistral": {
+		baseURL: "https://api.mistral.ai/v1", style: providers.TypeOpenAI,
+		authHeader: "Authorization", authPrefix: "Bearer ", verifyPath: "/models", needsToken: true,
+	},
+	"openrouter": {
+		baseURL: "https://openrouter.ai/api/v1", style: providers.TypeOpenAI,
+		authHeader: "Authorization", authPrefix: "Bearer ", verifyPath: "/auth/key", needsToken: true,
+	},
+	"ollama": {
+		baseURL: "http://localhost:11434", verifyPath: "/api/tags",
+	},
+	"azure-openai": {
+		style: providers.TypeOpenAI, authHeader: "api-key", verifyPath: "/openai/models", needsToken: true,
+	},
+	config.OpenAICompatible: {
+		style: providers.TypeOpenAI, authHeader: "Authorization", authPrefix: "Bearer ", verifyPath: "/models",
+	},
+}
+
+// ProviderNeedsToken reports whether providers of kind require an API token.
+// Local servers such as Ollama usually run without one.
+func ProviderNeedsToken(kind string) bool {
+	return providerAPIs[kind].needsToken
+}
+
+// providerEndpoint is a provider's API with its configured settings applied
+type providerEndpoint struct {
+	providerAPI
+	kind    string
+	baseURL string
+	headers map[string]string
+	params  map[string]string
+}
+
+// resolveEndpoint combines the API of kind with the provider's settings
+func resolveEndpoint(kind string, settings config.ProviderConfig) (providerEndpoint, error) {
+	api, ok := providerAPIs[kind]
+	if !ok {
+		return providerEndpoint{}, fmt.Errorf("unsupported provider type: %s", kind)
+	}
+
+	endpoint := providerEndpoint{
+		providerAPI: api,
+		kind:        kind,
+		baseURL:     strings.TrimSuffix(api.baseURL, "/"),
+		headers:     make(map[string]string),
+		params:      make(map[string]string),
+	}
+	if settings.BaseURL != "" {
+		endpoint.baseURL = strings.TrimSuffix(settings.BaseURL, "/")
+	}
+	if endpoint.baseURL == "" {
+		return providerEndpoint{}, fmt.Errorf("%s needs a base_url", kind)
+	}
+
+	for name, value := range api.headers {
+		endpoint.headers[name] = value
+	}
+	for name, value := range settings.Headers {
+		endpoint.headers[name] = value
+	}
+
+	if kind == "azure-openai" {
+		apiVersion := settings.APIVersion
+		if apiVersion == "" {
+			apiVersion = defaultAzureAPIVersion
+		}
+		endpoint.params["api-version"] = apiVersion
+	}
+
+	return endpoint, nil
+}
+
+// chatURL returns the address that generation requests are sent to
+func (e providerEndpoint) chatURL(deployment string) (string, error) {
+	switch {
+	case e.kind == "azure-openai":
+		if deployment == "" {
+			return "", fmt.Errorf("azure-openai needs a deployment")
+		}
+		return e.baseURL + "/openai/deployments/" + deployment + "/chat/completions", nil
+	case e.style == providers.TypeAnthropic:
+		return e.baseURL + "/v1/messages", nil
+	}
+	return e.baseURL + "/chat/completions", nil
+}
+
+// usesGenericProvider reports whether the provider must be reached through
+// gollm's generic provider, because its address is not gollm's default or
+// gollm has no built-in support for it
+func usesGenericProvider(kind string, settings config.ProviderConfig) bool {
+	switch kind {
+	case "ollama":
+		return false
+	case "openrouter", "azure-openai", config.OpenAICompatible:
+		return true
+	}
+	return settings.BaseURL != ""
+}
+
+// newLLM connects to the provider called name, of the given kind, and
+// returns a function that generates a completion for a prompt, built with
+// promptOptions
+func newLLM(name, kind string, settings config.ProviderConfig, token string, options []gollm.ConfigOption, promptOptions []gollm.PromptOption) (generateFunc, error) {
+	if token == "" {
+		token = noToken
+	}
+
+	if len(settings.Headers) > 0 {
+		options = append(options, gollm.SetExtraHeaders(settings.Headers))
+	}
+
+	if !usesGenericProvider(kind, settings) {
+		options = append([]gollm.ConfigOption{gollm.SetProvider(kind), gollm.SetAPIKey(token)}, options...)
+		if kind == "ollama" && settings.BaseURL != "" {
+			options = append(options, gollm.SetOllamaEndpoint(strings.TrimSuffix(settings.BaseURL, "/")))
+		}
+
+		llm, err := gollm.NewLLM(options...)
+		if err != nil {
+			return nil, err
+		}
+		if err := useObservingClient(llm); err != nil {
+			return nil, err
+		}
+		// gollm reads streams as server-sent events, which Ollama does not send
+		return generateWith(llm, kind != "ollama", promptOptions), nil
+	}
+
+	endpoint, err := resolveEndpoint(kind, settings)
+	if err != nil {
+		return nil, fmt.Errorf("provider %s: %w", name, err)
+	}
+	chatURL, err := endpoint.chatURL(settings.Deployment)
+	if err != nil {
+		return nil, fmt.Errorf("provider %s: %w", name, err)
+	}
+
+	required := map[string]string{"Content-Type": "application/json"}
+	for header, value := range endpoint.headers {
+		required[header] = value
+	}
+
+	// gollm.NewLLM only knows its built-in providers, so the generic
+	// provider is registered globally and the LLM is built directly
+	registered := "pseudolang-" + name
+	providers.RegisterGenericProvider(registered, providers.ProviderConfig{
+		Name:              registered,
+		Type:              endpoint.style,
+		Endpoint:          chatURL,
+		AuthHeader:        endpoint.authHeader,
+		AuthPrefix:        endpoint.authPrefix,
+		RequiredHeaders:   required,
+		EndpointParams:    endpoint.params,
+		SupportsSchema:    true,
+		SupportsStreaming: true,
+	})
+
+	cfg, err := gollmconfig.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	for _, option := range append([]gollm.ConfigOption{gollm.SetProvider(registered), gollm.SetAPIKey(token)}, options...) {
+		option(cfg)
+	}
+
+	llm, err := gollmllm.NewLLM(cfg, utils.NewLogger(cfg.LogLevel), providers.GetDefaultRegistry())
+	if err != nil {
+		return nil, err
+	}
+	if err := useObservingClient(llm); err != nil {
+		return nil, err
+	}
+	return generateWith(llm, true, promptOptions), nil
+}
+
+// useObservingClient gives llm an HTTP client of its own whose responses can
+// be observed, keeping its timeout. gollm has no option for passing a client,
+// so the one it built is replaced in the *llm.LLMImpl that makes requests,
+// which gollm.NewLLM wraps.
+func useObservingClient(llm gollmllm.LLM) error {
+	impl, ok := llm.(*gollmllm.LLMImpl)
+	if !ok {
+		if v := reflect.ValueOf(llm); v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Struct {
+			if embedded := v.Elem().FieldByName("LLM"); embedded.IsValid() && embedded.CanInterface() {
+				impl, ok = embedded.Interface().(*gollmllm.LLMImpl)
+			}
+		}
+	}
+	if !ok || impl == nil {
+		return fmt.Errorf("cannot observe requests made by %T", llm)
+	}
+
+	field := reflect.ValueOf(impl).Elem().FieldByName("client")
+	if !field.IsValid() || field.Type() != reflect.TypeFor[*http.Client]() {
+		return fmt.Errorf("cannot observe requests made by %T", llm)
+	}
+	client := (**http.Client)(unsafe.Pointer(field.UnsafeAddr()))
+
+	var timeout time.Duration
+	if *client != nil {
+		timeout = (*client).Timeout
+	}
+	*client = newObservingClient(timeout)
+	return nil
+}
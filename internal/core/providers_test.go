@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/teilomillet/gollm"
+	"github.com/username/pseudolang/internal/config"
+)
+
+func TestNewLLM_OpenAICompatible(t *testing.T) {
+	var gotPath, gotQuery, gotAuth, gotHeader, gotModel string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotQuery = r.URL.Query().Get("api-version")
+		gotAuth = r.Header.Get("Authorization") + r.Header.Get("api-key")
+		gotHeader = r.Header.Get("X-Team")
+
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		gotModel = body.Model
+
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "<code>print(1)</code>"}}]}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		kind      string
+		settings  config.ProviderConfig
+		token     string
+		wantPath  string
+		wantQuery string
+		wantAuth  string
+	}{
+		{
+			name:     "local server without a token",
+			kind:     config.OpenAICompatible,
+			settings: config.ProviderConfig{BaseURL: server.URL + "/v1/", Headers: map[string]string{"X-Team": "compilers"}},
+			wantPath: "/v1/chat/completions",
+			wantAuth: "Bearer " + noToken,
+		},
+		{
+			name:     "built-in provider at a custom address",
+			kind:     "openai",
+			settings: config.ProviderConfig{BaseURL: server.URL + "/v1", Headers: map[string]string{"X-Team": "compilers"}},
+			token:    "sk-test",
+			wantPath: "/v1/chat/completions",
+			wantAuth: "Bearer sk-test",
+		},
+		{
+			name: "azure deployment",
+			kind: "azure-openai",
+			settings: config.ProviderConfig{
+				BaseURL:    server.URL,
+				Deployment: "gpt4o-prod",
+				APIVersion: "2024-06-01",
+				Headers:    map[string]string{"X-Team": "compilers"},
+			},
+			token:     "azure-key",
+			wantPath:  "/openai/deployments/gpt4o-prod/chat/completions",
+			wantQuery: "2024-06-01",
+			wantAuth:  "azure-key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generate, err := newLLM("test", tt.kind, tt.settings, tt.token, []gollm.ConfigOption{gollm.SetModel("llama-3-8b")}, nil)
+			if err != nil {
+				t.Fatalf("newLLM() unexpected error = %v", err)
+			}
+
+			got, err := generate(context.Background(), "hello")
+			if err != nil {
+				t.Fatalf("generate() unexpected error = %v", err)
+			}
+			if got != "<code>print(1)</code>" {
+				t.Errorf("generate() = %q, want the server's reply", got)
+			}
+
+			if gotPath != tt.wantPath {
+				t.Errorf("request path = %q, want %q", gotPath, tt.wantPath)
+			}
+			if gotQuery != tt.wantQuery {
+				t.Errorf("request api-version = %q, want %q", gotQuery, tt.wantQuery)
+			}
+			if gotAuth != tt.wantAuth {
+				t.Errorf("request auth = %q, want %q", gotAuth, tt.wantAuth)
+			}
+			if gotHeader != "compilers" {
+				t.Errorf("request X-Team header = %q, want compilers", gotHeader)
+			}
+			if gotModel != "llama-3-8b" {
+				t.Errorf("request model = %q, want llama-3-8b", gotModel)
+			}
+		})
+	}
+
+	if _, err := newLLM("test", "azure-openai", config.ProviderConfig{BaseURL: server.URL}, "key", nil, nil); err == nil {
+		t.Errorf("newLLM() expected error for azure without a deployment but got none")
+	}
+}
+
+func TestNewLLM_SystemPrompt(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	defer server.Close()
+
+	settings := config.ProviderConfig{BaseURL: server.URL}
+	promptOptions := []gollm.PromptOption{gollm.WithSystemPrompt("Prefer list comprehensions.", "")}
+	generate, err := newLLM("test", config.OpenAICompatible, settings, "", []gollm.ConfigOption{gollm.SetModel("llama-3-8b")}, promptOptions)
+	if err != nil {
+		t.Fatalf("newLLM() unexpected error = %v", err)
+	}
+	if _, err := generate(context.Background(), "hello"); err != nil {
+		t.Fatalf("generate() unexpected error = %v", err)
+	}
+
+	// gollm sends the system prompt as a system message or, for generic
+	// providers, ahead of the prompt text
+	if !strings.Contains(string(body), "Prefer list comprehensions.") {
+		t.Errorf("request body = %s, want it to include the system prompt", body)
+	}
+}
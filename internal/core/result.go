@@ -0,0 +1,84 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// RunResult is the JSON document printed for a run or build in JSON output
+// mode
+type RunResult struct {
+	// ID is the run's ID in the history; empty for builds
+	ID       string `json:"id,omitempty"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+
+	// Code is the generated Python of a single-file program; Modules holds
+	// the modules of a multi-file program and Entry the one that is run
+	Code        string            `json:"code,omitempty"`
+	Modules     map[string]string `json:"modules,omitempty"`
+	Entry       string            `json:"entry,omitempty"`
+	Assumptions []string          `json:"assumptions"`
+
+	// Stdout, Stderr and ExitCode are only present once the code has run
+	Stdout   *string `json:"stdout,omitempty"`
+	Stderr   *string `json:"stderr,omitempty"`
+	ExitCode *int    `json:"exit_code,omitempty"`
+	// Error is why the run failed, if it did
+	Error string `json:"error,omitempty"`
+
+	Timings ResultTimings `json:"timings"`
+	Usage   []ModelUsage  `json:"usage"`
+}
+
+// ResultTimings are the durations of a run's steps in milliseconds
+type ResultTimings struct {
+	TranslateMs float64 `json:"translate_ms"`
+	ExecuteMs   float64 `json:"execute_ms"`
+	TotalMs     float64 `json:"total_ms"`
+}
+
+// Result returns the JSON document describing r
+func (r *RunRecord) Result() RunResult {
+	result := RunResult{
+		ID:          r.ID,
+		Provider:    r.Provider,
+		Model:       r.Model,
+		Code:        r.Code,
+		Modules:     r.Modules,
+		Entry:       r.Entry,
+		Assumptions: r.Assumptions,
+		Error:       r.Error,
+		Timings: ResultTimings{
+			TranslateMs: milliseconds(r.TranslateTime),
+			ExecuteMs:   milliseconds(r.ExecuteTime),
+			TotalMs:     milliseconds(r.TranslateTime + r.ExecuteTime),
+		},
+		Usage: r.Usage,
+	}
+
+	if r.executed {
+		stdout, stderr, exitCode := r.Stdout, r.Stderr, r.ExitCode
+		result.Stdout, result.Stderr, result.ExitCode = &stdout, &stderr, &exitCode
+	}
+	// Tools can rely on the lists being present
+	if result.Assumptions == nil {
+		result.Assumptions = []string{}
+	}
+	if result.Usage == nil {
+		result.Usage = []ModelUsage{}
+	}
+	return result
+}
+
+// WriteJSON writes the JSON document describing r to w
+func (r *RunRecord) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r.Result())
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,356 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/username/pseudolang/internal/config"
+	"github.com/username/pseudolang/internal/logging"
+)
+
+// Default retry settings, used when the config leaves them unset
+const (
+	DefaultMaxRetries   = 3
+	DefaultInitialDelay = time.Second
+	DefaultMaxDelay     = 30 * time.Second
+)
+
+// maxErrorBody bounds how much of an error response is kept for messages
+const maxErrorBody = 512
+
+// APIError is a request to a provider that failed after it was sent
+type APIError struct {
+	Provider string
+	Model    string
+	// StatusCode is the HTTP status of the response, or 0 when no response
+	// was received
+	StatusCode int
+	// RetryAfter is how long the provider asked us to wait, if it did
+	RetryAfter time.Duration
+	// Body is the start of the error response
+	Body string
+	Err  error
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s/%s: ", e.Provider, e.Model)
+	if e.StatusCode == 0 {
+		fmt.Fprintf(&b, "request failed: %v", e.Err)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if body := strings.TrimSpace(e.Body); body != "" {
+		fmt.Fprintf(&b, ": %s", body)
+	}
+	return b.String()
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether the request may succeed if tried again: no
+// response was received, the provider is rate limiting, or it had a server
+// error
+func (e *APIError) Temporary() bool {
+	switch {
+	case e.StatusCode == 0:
+		return true
+	case e.StatusCode == http.StatusTooManyRequests, e.StatusCode == http.StatusRequestTimeout:
+		return true
+	case e.StatusCode >= 500:
+		return true
+	}
+	return false
+}
+
+// isUnavailable reports whether err means the model could not be reached,
+// as opposed to a request that can never succeed
+func isUnavailable(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Temporary()
+}
+
+// RetryPolicy controls how requests that fail with a temporary error are
+// retried
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+	// InitialDelay is the backoff before the first retry; it doubles with
+	// every retry up to MaxDelay
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	// Notify receives a line for every retry; nil discards them
+	Notify io.Writer
+
+	// sleep waits for d or until ctx is done; replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// retryPolicy builds the retry policy from the config's retry settings
+func retryPolicy(options config.RetryOptions) (RetryPolicy, error) {
+	policy := RetryPolicy{
+		MaxRetries:   DefaultMaxRetries,
+		InitialDelay: DefaultInitialDelay,
+		MaxDelay:     DefaultMaxDelay,
+	}
+
+	if options.MaxRetries != nil {
+		policy.MaxRetries = *options.MaxRetries
+	}
+	if options.InitialDelay != "" {
+		d, err := time.ParseDuration(options.InitialDelay)
+		if err != nil {
+			return policy, fmt.Errorf("invalid retry.initial_delay: %w", err)
+		}
+		policy.InitialDelay = d
+	}
+	if options.MaxDelay != "" {
+		d, err := time.ParseDuration(options.MaxDelay)
+		if err != nil {
+			return policy, fmt.Errorf("invalid retry.max_delay: %w", err)
+		}
+		policy.MaxDelay = d
+	}
+	return policy, nil
+}
+
+// backoff returns the delay before retry number attempt (counting from 0):
+// exponential backoff with jitter, and at least the provider's Retry-After
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := p.InitialDelay
+	for i := 0; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+
+	// Equal jitter: half fixed, half random, so clients spread out without
+	// retrying immediately
+	if half := delay / 2; half > 0 {
+		delay = half + rand.N(half+1)
+	}
+
+	return max(delay, retryAfter)
+}
+
+// do calls fn until it succeeds, fails with an error that is not temporary,
+// or runs out of retries. A Retry-After longer than MaxDelay ends the retries
+// early, since waiting that long is worse than falling back.
+func (p RetryPolicy) do(ctx context.Context, fn func() (string, error)) (string, error) {
+	sleep := p.sleep
+	if sleep == nil {
+		sleep = sleepContext
+	}
+
+	for attempt := 0; ; attempt++ {
+		out, err := fn()
+
+		var apiErr *APIError
+		if err == nil || attempt >= p.MaxRetries || !errors.As(err, &apiErr) || !apiErr.Temporary() {
+			return out, err
+		}
+		if apiErr.RetryAfter > p.MaxDelay {
+			return "", err
+		}
+
+		delay := p.backoff(attempt, apiErr.RetryAfter)
+		if p.Notify != nil {
+			fmt.Fprintf(p.Notify, "%v; retrying in %s (%d/%d)\n", err, delay.Round(100*time.Millisecond), attempt+1, p.MaxRetries)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return "", err
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseRetryAfter reads how long a response asks the client to wait, from
+// Retry-After in seconds or as a date, or OpenAI's retry-after-ms
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// gollm hides the HTTP response behind a generic error, so responses are
+// observed at the transport of each connected model's HTTP client instead.
+// Only requests whose context carries an exchange are recorded; other
+// traffic passes through untouched.
+
+type exchangeKey struct{}
+
+// exchange records the outcome of the HTTP request made with its context
+type exchange struct {
+	mu     sync.Mutex
+	sent   bool
+	status int
+	header http.Header
+	body   []byte
+	err    error
+	// response is a copy of a successful response body, made as the
+	// provider's client reads it
+	response bytes.Buffer
+	// bodies are the response bodies received, for closing those the
+	// provider's client leaves open
+	bodies []io.Closer
+}
+
+// observe returns a context whose HTTP requests are recorded in the
+// returned exchange
+func observe(ctx context.Context) (context.Context, *exchange) {
+	ex := &exchange{}
+	return context.WithValue(ctx, exchangeKey{}, ex), ex
+}
+
+// observingTransport records the responses to requests made with an
+// exchange in their context
+type observingTransport struct {
+	base http.RoundTripper
+}
+
+// newObservingClient returns an HTTP client whose responses can be observed,
+// giving up on requests after timeout unless it is zero
+func newObservingClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: &observingTransport{base: http.DefaultTransport}, Timeout: timeout}
+}
+
+func (t *observingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+
+	ex, ok := req.Context().Value(exchangeKey{}).(*exchange)
+	if !ok {
+		return resp, err
+	}
+
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+
+	ex.sent = true
+	ex.err = err
+	if resp == nil {
+		return resp, err
+	}
+
+	ex.status = resp.StatusCode
+	ex.header = resp.Header
+	ex.bodies = append(ex.bodies, resp.Body)
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), resp.Body))
+		ex.body = body
+	} else {
+		ex.response.Reset()
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(resp.Body, &ex.response), resp.Body}
+	}
+	return resp, err
+}
+
+// closeBodies closes the response bodies recorded in ex. gollm's streams
+// leave the body open when they are closed, which would hold on to the
+// connection.
+func (ex *exchange) closeBodies() {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+
+	for _, body := range ex.bodies {
+		_ = body.Close()
+	}
+	ex.bodies = nil
+}
+
+// apiError describes the failed request recorded in ex. err is returned
+// unchanged when no request was sent.
+func (ex *exchange) apiError(ctx context.Context, provider, model string, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+
+	if !ex.sent {
+		return err
+	}
+
+	apiErr := &APIError{Provider: provider, Model: model, StatusCode: ex.status, Err: err}
+	if ex.err != nil {
+		apiErr.Err = ex.err
+	}
+	if ex.header != nil {
+		apiErr.RetryAfter = parseRetryAfter(ex.header, time.Now())
+	}
+	apiErr.Body = string(ex.body)
+	return apiErr
+}
+
+// usage returns the token counts reported in the successful response
+// recorded in ex
+func (ex *exchange) usage() (Usage, bool) {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	return parseUsage(ex.response.Bytes())
+}
+
+// observed wraps generate so that its failures are reported as *APIError
+// when a request reached the network, and its successes are recorded in
+// meter with the token counts the provider reported, or estimates. Every
+// request is logged as a generation span and dumped if the context asks.
+func observed(generate generateFunc, provider, model string, meter *UsageMeter) generateFunc {
+	return func(ctx context.Context, prompt string) (out string, err error) {
+		ctx, span := logging.Start(ctx, "generation", "provider", provider, "model", model, "prompt_bytes", len(prompt))
+		defer func() { span.End(err) }()
+		dumped := dumpRequest(ctx, provider, model, prompt)
+		defer func() { dumped(out, err) }()
+
+		ctx, ex := observe(ctx)
+		out, err = generate(ctx, prompt)
+		if err != nil {
+			return "", ex.apiError(ctx, provider, model, err)
+		}
+
+		usage, ok := ex.usage()
+		if !ok {
+			usage = estimateUsage(prompt, out)
+		}
+		meter.record(provider, model, usage)
+		span.Add("response_bytes", len(out), "prompt_tokens", usage.PromptTokens,
+			"completion_tokens", usage.CompletionTokens, "estimated", usage.Estimated)
+		return out, nil
+	}
+}
@@ -0,0 +1,214 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teilomillet/gollm"
+	"github.com/username/pseudolang/internal/config"
+)
+
+// testPolicy returns a policy that records its delays instead of sleeping
+func testPolicy(retries int, delays *[]time.Duration) RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:   retries,
+		InitialDelay: time.Second,
+		MaxDelay:     10 * time.Second,
+		sleep: func(ctx context.Context, d time.Duration) error {
+			*delays = append(*delays, d)
+			return nil
+		},
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	unavailable := &APIError{Provider: "openai", Model: "gpt-4o", StatusCode: http.StatusServiceUnavailable}
+	rateLimited := &APIError{Provider: "openai", Model: "gpt-4o", StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Second}
+	unauthorized := &APIError{Provider: "openai", Model: "gpt-4o", StatusCode: http.StatusUnauthorized}
+	tooLong := &APIError{Provider: "openai", Model: "gpt-4o", StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   bool
+		check     func(t *testing.T, delays []time.Duration)
+	}{
+		{
+			name:      "retries server errors with growing backoff",
+			errs:      []error{unavailable, unavailable, nil},
+			wantCalls: 3,
+			check: func(t *testing.T, delays []time.Duration) {
+				if len(delays) != 2 || delays[0] < 500*time.Millisecond || delays[0] > time.Second || delays[1] < time.Second || delays[1] > 2*time.Second {
+					t.Errorf("delays = %v, want about 1s then about 2s", delays)
+				}
+			},
+		},
+		{
+			name:      "honors Retry-After",
+			errs:      []error{rateLimited, nil},
+			wantCalls: 2,
+			check: func(t *testing.T, delays []time.Duration) {
+				if len(delays) != 1 || delays[0] < 5*time.Second {
+					t.Errorf("delays = %v, want at least the 5s Retry-After", delays)
+				}
+			},
+		},
+		{
+			name:      "gives up after max retries",
+			errs:      []error{unavailable, unavailable, unavailable, unavailable},
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			name:      "does not retry client errors",
+			errs:      []error{unauthorized, nil},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name:      "does not wait for a Retry-After beyond the max delay",
+			errs:      []error{tooLong, nil},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name:      "does not retry errors without a response",
+			errs:      []error{errors.New("invalid request"), nil},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var delays []time.Duration
+			policy := testPolicy(2, &delays)
+
+			calls := 0
+			out, err := policy.do(context.Background(), func() (string, error) {
+				err := tt.errs[calls]
+				calls++
+				if err != nil {
+					return "", err
+				}
+				return "ok", nil
+			})
+
+			if calls != tt.wantCalls {
+				t.Errorf("do() made %d calls, want %d", calls, tt.wantCalls)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && out != "ok" {
+				t.Errorf("do() = %q, want ok", out)
+			}
+			if tt.check != nil {
+				tt.check(t, delays)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 10, 25, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"seconds", http.Header{"Retry-After": {"7"}}, 7 * time.Second},
+		{"date", http.Header{"Retry-After": {now.Add(90 * time.Second).Format(http.TimeFormat)}}, 90 * time.Second},
+		{"past date", http.Header{"Retry-After": {now.Add(-time.Minute).Format(http.TimeFormat)}}, 0},
+		{"milliseconds", http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"1"}}, 250 * time.Millisecond},
+		{"missing", http.Header{}, 0},
+		{"garbage", http.Header{"Retry-After": {"soon"}}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestObserved_ReportsStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error": "slow down"}`))
+	}))
+	defer server.Close()
+
+	generate, err := newLLM("local", config.OpenAICompatible, config.ProviderConfig{BaseURL: server.URL}, "",
+		[]gollm.ConfigOption{gollm.SetModel("llama-3-8b"), gollm.SetMaxRetries(0)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = observed(generate, "local", "llama-3-8b", nil)(context.Background(), "hello")
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("generate() error = %v, want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.RetryAfter != 3*time.Second || !apiErr.Temporary() {
+		t.Errorf("APIError = %+v, want a temporary 429 with a 3s Retry-After", apiErr)
+	}
+	if !strings.Contains(apiErr.Error(), "slow down") || !strings.Contains(apiErr.Error(), "local/llama-3-8b") {
+		t.Errorf("APIError.Error() = %q, want the model and the response body", apiErr.Error())
+	}
+	if _, ok := http.DefaultTransport.(*observingTransport); ok {
+		t.Errorf("http.DefaultTransport was replaced, want only the model's client observed")
+	}
+}
+
+func TestUseObservingClient(t *testing.T) {
+	for _, provider := range []string{"openai", config.OpenAICompatible} {
+		settings := config.ProviderConfig{}
+		if provider == config.OpenAICompatible {
+			settings.BaseURL = "http://127.0.0.1:1/v1"
+		}
+		// newLLM fails when the client gollm built cannot be replaced
+		if _, err := newLLM("test", provider, settings, "sk-"+strings.Repeat("a", 48), []gollm.ConfigOption{gollm.SetModel("gpt-4o")}, nil); err != nil {
+			t.Errorf("newLLM(%s) error = %v", provider, err)
+		}
+	}
+}
+
+func TestExchange_CloseBodies(t *testing.T) {
+	closed := make(chan bool, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		w.(http.Flusher).Flush()
+
+		// Hold the response open, as a stream ended early by its reader is
+		select {
+		case <-r.Context().Done():
+			closed <- true
+		case <-time.After(2 * time.Second):
+			closed <- false
+		}
+	}))
+	defer server.Close()
+
+	ctx, ex := observe(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	resp, err := newObservingClient(0).Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	_, _ = resp.Body.Read(make([]byte, 16))
+
+	ex.closeBodies()
+	if !<-closed {
+		t.Errorf("response body left open")
+	}
+}
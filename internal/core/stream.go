@@ -0,0 +1,264 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	gollmllm "github.com/teilomillet/gollm/llm"
+	"github.com/username/pseudolang/internal/logging"
+)
+
+// progressInterval is how often the progress line is redrawn
+const progressInterval = 100 * time.Millisecond
+
+const (
+	analysisOpenTag  = "<conversion_analysis>"
+	analysisCloseTag = "</conversion_analysis>"
+)
+
+// generateWith returns a generateFunc for llm. The response is streamed when
+// stream is set, the provider supports it and the context carries a
+// liveOutput to show it in; otherwise it is generated in one request.
+func generateWith(llm gollmllm.LLM, stream bool, promptOptions []gollmllm.PromptOption) generateFunc {
+	return func(ctx context.Context, text string) (string, error) {
+		prompt := gollmllm.NewPrompt(text, promptOptions...)
+
+		live, ok := ctx.Value(liveKey{}).(*liveOutput)
+		if !ok || !stream || !llm.SupportsStreaming() {
+			return llm.Generate(ctx, prompt)
+		}
+		return streamResponse(ctx, llm, prompt, live)
+	}
+}
+
+// streamResponse generates a response as a stream, showing it in live as it
+// arrives. The response body is closed once the stream ends, whether or not
+// it completed.
+func streamResponse(ctx context.Context, llm gollmllm.LLM, prompt *gollmllm.Prompt, live *liveOutput) (string, error) {
+	ex, ok := ctx.Value(exchangeKey{}).(*exchange)
+	if !ok {
+		ctx, ex = observe(ctx)
+	}
+	defer ex.closeBodies()
+
+	tokens, err := llm.Stream(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	defer tokens.Close()
+
+	response := live.response()
+	defer response.end()
+
+	var text strings.Builder
+	for {
+		token, err := tokens.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return text.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		text.WriteString(token.Text)
+		response.write(token.Text)
+	}
+}
+
+type liveKey struct{}
+
+// liveOutput shows responses while they stream in: a progress line with the
+// tokens received and the time elapsed when writing to a terminal, and the
+// model's conversion analysis when asked for. It is also an io.Writer for
+// other messages, which are written without garbling the progress line.
+type liveOutput struct {
+	w            io.Writer
+	progress     bool
+	showAnalysis bool
+
+	mu      sync.Mutex
+	started time.Time
+	// received is the text of the responses streamed so far. tokens is its
+	// length in tokens, last counted when it was counted bytes long.
+	received strings.Builder
+	tokens   int
+	counted  int
+	// drawn is set while a progress line is on screen
+	drawn bool
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// newLiveOutput returns live output written to f. The progress line is only
+// drawn when f is a terminal, and not while the log is written to stderr,
+// whose records it would overwrite.
+func newLiveOutput(f *os.File, showAnalysis bool) *liveOutput {
+	progress := isTerminal(f) && !logging.ToStderr()
+	return &liveOutput{w: f, progress: progress, showAnalysis: showAnalysis}
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// start returns a context whose responses are streamed into l, and starts
+// redrawing the progress line. Without anything to show, generation is left
+// alone and ctx is returned unchanged.
+func (l *liveOutput) start(ctx context.Context) context.Context {
+	if !l.progress && !l.showAnalysis {
+		return ctx
+	}
+
+	l.started = time.Now()
+	if l.progress {
+		l.stop = make(chan struct{})
+		l.done = make(chan struct{})
+		go l.redraw()
+	}
+	return context.WithValue(ctx, liveKey{}, l)
+}
+
+// finish stops redrawing and clears the progress line
+func (l *liveOutput) finish() {
+	if l.stop == nil {
+		return
+	}
+	close(l.stop)
+	<-l.done
+	l.stop = nil
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clear()
+}
+
+func (l *liveOutput) redraw() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			l.draw()
+			l.mu.Unlock()
+		}
+	}
+}
+
+// draw writes the progress line over the previous one; l.mu must be held
+func (l *liveOutput) draw() {
+	// The text is only counted again once more of it has arrived
+	if l.received.Len() != l.counted {
+		l.tokens = CountTokens(l.received.String())
+		l.counted = l.received.Len()
+	}
+
+	elapsed := time.Since(l.started).Seconds()
+	fmt.Fprintf(l.w, "\r\033[KTranslating: %d tokens received, %.1fs", l.tokens, elapsed)
+	l.drawn = true
+}
+
+// clear erases the progress line; l.mu must be held
+func (l *liveOutput) clear() {
+	if l.drawn {
+		fmt.Fprint(l.w, "\r\033[K")
+		l.drawn = false
+	}
+}
+
+// Write writes p above the progress line
+func (l *liveOutput) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.clear()
+	return l.w.Write(p)
+}
+
+// response starts showing one streamed response
+func (l *liveOutput) response() *liveResponse {
+	return &liveResponse{live: l}
+}
+
+// liveResponse follows one response as it streams in, echoing the complete
+// lines of its conversion analysis
+type liveResponse struct {
+	live *liveOutput
+	text strings.Builder
+	// echoed is how much of the response has been searched for analysis
+	// lines to echo
+	echoed     int
+	inAnalysis bool
+}
+
+// write adds the next piece of the response
+func (r *liveResponse) write(text string) {
+	r.live.mu.Lock()
+	r.live.received.WriteString(text)
+	r.live.mu.Unlock()
+
+	if !r.live.showAnalysis {
+		return
+	}
+	r.text.WriteString(text)
+	r.echo(false)
+}
+
+// end echoes what is left of the analysis once the response is complete
+func (r *liveResponse) end() {
+	if r.live.showAnalysis {
+		r.echo(true)
+	}
+}
+
+// echo writes the analysis lines received since the last call. A line is
+// only written once it is complete, unless final is set.
+func (r *liveResponse) echo(final bool) {
+	full := r.text.String()
+	for {
+		rest := full[r.echoed:]
+		if !r.inAnalysis {
+			start := strings.Index(rest, analysisOpenTag)
+			if start < 0 {
+				// Keep back enough to find a tag split across pieces
+				r.echoed = max(r.echoed, len(full)-len(analysisOpenTag))
+				return
+			}
+			r.echoed += start + len(analysisOpenTag)
+			r.inAnalysis = true
+			continue
+		}
+
+		line, _, complete := strings.Cut(rest, "\n")
+		if !complete && !final {
+			return
+		}
+
+		before, _, closed := strings.Cut(line, analysisCloseTag)
+		if strings.TrimSpace(before) != "" {
+			fmt.Fprintln(r.live, strings.TrimRight(before, "\r"))
+		}
+		if closed {
+			r.echoed += len(before) + len(analysisCloseTag)
+			r.inAnalysis = false
+			continue
+		}
+		if !complete {
+			r.echoed = len(full)
+			return
+		}
+		r.echoed += len(line) + 1
+	}
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teilomillet/gollm"
+	"github.com/username/pseudolang/internal/config"
+)
+
+func TestLiveResponse_EchoesAnalysis(t *testing.T) {
+	response := "Sure.\n<conversion_analysis>\nLine one\nLine two</conversion_analysis>\n<code>\nprint(1)\n</code>"
+
+	// Split the response into small pieces, so tags and lines arrive
+	// across several of them
+	var out strings.Builder
+	live := &liveOutput{w: &out, showAnalysis: true}
+	r := live.response()
+	for i := 0; i < len(response); i += 3 {
+		r.write(response[i:min(i+3, len(response))])
+	}
+	r.end()
+
+	if got, want := out.String(), "Line one\nLine two\n"; got != want {
+		t.Errorf("echoed %q, want %q", got, want)
+	}
+	if live.received.String() != response {
+		t.Errorf("received %q, want the whole response", live.received.String())
+	}
+}
+
+func TestLiveOutput_DrawCountsTokens(t *testing.T) {
+	var out strings.Builder
+	live := &liveOutput{w: &out, started: time.Now()}
+	r := live.response()
+	for _, piece := range []string{"<code>\n", "print(", "'hello world')\n", "</code>"} {
+		r.write(piece)
+	}
+
+	live.draw()
+	want := fmt.Sprintf("Translating: %d tokens received", CountTokens("<code>\nprint('hello world')\n</code>"))
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("progress line = %q, want it to contain %q", out.String(), want)
+	}
+}
+
+func TestLiveResponse_EchoesUnfinishedAnalysis(t *testing.T) {
+	var out strings.Builder
+	live := &liveOutput{w: &out, showAnalysis: true}
+	r := live.response()
+	r.write("<conversion_analysis>\nFirst\nCut off mid")
+	r.end()
+
+	if got, want := out.String(), "First\nCut off mid\n"; got != want {
+		t.Errorf("echoed %q, want %q", got, want)
+	}
+}
+
+func TestNewLLM_Streams(t *testing.T) {
+	var streamed bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		streamed = strings.Contains(string(body), `"stream":true`)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, piece := range []string{"<code>", "print(1)", "</code>"} {
+			_, _ = w.Write([]byte(`data: {"choices":[{"delta":{"content":"` + piece + `"}}]}` + "\n\n"))
+		}
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	settings := config.ProviderConfig{BaseURL: server.URL}
+	generate, err := newLLM("test", config.OpenAICompatible, settings, "", []gollm.ConfigOption{gollm.SetModel("llama-3-8b")}, nil)
+	if err != nil {
+		t.Fatalf("newLLM() unexpected error = %v", err)
+	}
+
+	live := &liveOutput{w: io.Discard, showAnalysis: true}
+	got, err := generate(live.start(context.Background()), "hello")
+	if err != nil {
+		t.Fatalf("generate() unexpected error = %v", err)
+	}
+	if !streamed {
+		t.Errorf("request did not ask for a stream")
+	}
+	if got != "<code>print(1)</code>" {
+		t.Errorf("generate() = %q, want the streamed pieces joined", got)
+	}
+	if live.received.String() != got {
+		t.Errorf("received %q, want the streamed response", live.received.String())
+	}
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// tokenizerEncoding is the BPE used to count tokens. Counts are an
+// approximation for non-OpenAI models but close enough for budgeting.
+const tokenizerEncoding = "cl100k_base"
+
+var (
+	encodingOnce sync.Once
+	encoding     *tiktoken.Tiktoken
+)
+
+// loadEncoding returns the tokenizer, or nil when it cannot be loaded. It is
+// the tiktoken-go that gollm already depends on; left alone it downloads its
+// vocabulary on first use, so the vocabulary is loaded from tiktoken-go's
+// companion loader, which embeds it in the binary, and counting never touches
+// the network.
+func loadEncoding() *tiktoken.Tiktoken {
+	encodingOnce.Do(func() {
+		tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+		if enc, err := tiktoken.GetEncoding(tokenizerEncoding); err == nil {
+			encoding = enc
+		}
+	})
+	return encoding
+}
+
+// CountTokens returns the number of tokens in text. When the tokenizer
+// vocabulary is unavailable it estimates roughly four characters per token.
+func CountTokens(text string) int {
+	if enc := loadEncoding(); enc != nil {
+		return len(enc.EncodeOrdinary(text))
+	}
+	return estimateTokens(text)
+}
+
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
@@ -0,0 +1,11 @@
+package core
+
+import "testing"
+
+func TestCountTokens(t *testing.T) {
+	// The embedded vocabulary must load without a network; the estimate
+	// would give 3
+	if got := CountTokens("hello world"); got != 2 {
+		t.Errorf("CountTokens(\"hello world\") = %d, want 2", got)
+	}
+}
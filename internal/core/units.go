@@ -0,0 +1,120 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+)
+
+// UnitKind distinguishes top-level definitions from loose statements
+type UnitKind string
+
+const (
+	UnitDefinition UnitKind = "definition"
+	UnitStatements UnitKind = "statements"
+)
+
+// Unit is a top-level piece of a pseudocode file: either one definition
+// (function, class, ...) or a run of statements between definitions
+type Unit struct {
+	Kind UnitKind
+	// Name is the defined identifier; empty for statement units
+	Name string
+	// Signature is the definition's header line without trailing ':' or '{'
+	Signature string
+	Text      string
+}
+
+var (
+	definitionRe = regexp.MustCompile(`(?i)^(?:async\s+)?(?:function|func|def|fn|procedure|proc|class|struct|record|method|sub|algorithm|define)\s+([A-Za-z_][A-Za-z0-9_]*)`)
+	blockCloseRe = regexp.MustCompile(`(?i)^(?:[})\]]|end\b|end[a-z]+\b|done\b)`)
+	commentRe    = regexp.MustCompile(`^(?:#|//|--|/\*|\*)`)
+)
+
+// SplitTopLevel splits pseudocode into top-level units. A definition starts
+// at an unindented line beginning with a definition keyword and continues
+// through indented, blank and block-closing lines. Unindented comments
+// directly above a definition belong to it. Everything else is grouped into
+// statement units.
+func SplitTopLevel(source string) []Unit {
+	var units []Unit
+	var current *Unit
+	var lines []string
+	var pending []string
+
+	flush := func() {
+		if current != nil {
+			current.Text = strings.TrimRight(strings.Join(lines, "\n"), "\n ")
+			if strings.TrimSpace(current.Text) != "" {
+				units = append(units, *current)
+			}
+		}
+		current, lines = nil, nil
+	}
+
+	for _, line := range strings.Split(source, "\n") {
+		trimmed := strings.TrimSpace(line)
+		indented := trimmed != "" && line[0] != trimmed[0]
+
+		switch {
+		case trimmed == "" || indented:
+			if current == nil {
+				pending = append(pending, line)
+				continue
+			}
+			lines = append(lines, line)
+		case current != nil && current.Kind == UnitDefinition && blockCloseRe.MatchString(trimmed):
+			lines = append(lines, line)
+		case commentRe.MatchString(trimmed):
+			// Held back so it can attach to a following definition
+			pending = append(pending, line)
+		case definitionRe.MatchString(trimmed):
+			flush()
+			current = &Unit{
+				Kind:      UnitDefinition,
+				Name:      definitionRe.FindStringSubmatch(trimmed)[1],
+				Signature: strings.TrimSpace(strings.TrimRight(trimmed, ":{ ")),
+			}
+			lines = append(lines, trimLeadingBlank(pending)...)
+			lines = append(lines, line)
+			pending = nil
+		default:
+			if current == nil || current.Kind != UnitStatements {
+				flush()
+				current = &Unit{Kind: UnitStatements}
+				pending = trimLeadingBlank(pending)
+			}
+			lines = append(lines, pending...)
+			lines = append(lines, line)
+			pending = nil
+		}
+	}
+
+	if current != nil {
+		lines = append(lines, pending...)
+	} else if len(trimLeadingBlank(pending)) > 0 {
+		current = &Unit{Kind: UnitStatements}
+		lines = trimLeadingBlank(pending)
+	}
+	flush()
+
+	return units
+}
+
+func trimLeadingBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	return lines
+}
+
+// InterfaceSummary lists the signatures of every definition in units, one
+// per line, so separately translated parts of a program agree on names
+func InterfaceSummary(units []Unit) string {
+	var signatures []string
+	for _, unit := range units {
+		if unit.Kind == UnitDefinition {
+			signatures = append(signatures, unit.Signature)
+		}
+	}
+	return strings.Join(signatures, "\n")
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestSplitTopLevel(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Unit
+	}{
+		{
+			name: "indented definitions and statements",
+			input: `function quicksort(xs):
+    if xs.length <= 1: return xs
+    return xs
+
+print( quicksort([3, 1, 2]) )`,
+			want: []Unit{
+				{Kind: UnitDefinition, Name: "quicksort", Signature: "function quicksort(xs)", Text: "function quicksort(xs):\n    if xs.length <= 1: return xs\n    return xs"},
+				{Kind: UnitStatements, Text: "print( quicksort([3, 1, 2]) )"},
+			},
+		},
+		{
+			name: "brace and end delimited blocks",
+			input: `fn add(a, b) {
+  return a + b
+}
+PROCEDURE greet(name)
+  print "hi " + name
+END PROCEDURE`,
+			want: []Unit{
+				{Kind: UnitDefinition, Name: "add", Signature: "fn add(a, b)", Text: "fn add(a, b) {\n  return a + b\n}"},
+				{Kind: UnitDefinition, Name: "greet", Signature: "PROCEDURE greet(name)", Text: "PROCEDURE greet(name)\n  print \"hi \" + name\nEND PROCEDURE"},
+			},
+		},
+		{
+			name: "comments attach to the following definition",
+			input: `let x = 1
+
+# doubles a number
+def double(n):
+    return n * 2`,
+			want: []Unit{
+				{Kind: UnitStatements, Text: "let x = 1"},
+				{Kind: UnitDefinition, Name: "double", Signature: "def double(n)", Text: "# doubles a number\ndef double(n):\n    return n * 2"},
+			},
+		},
+		{
+			name: "consecutive statements form one unit",
+			input: `let a = 1
+let b = 2
+print a + b`,
+			want: []Unit{
+				{Kind: UnitStatements, Text: "let a = 1\nlet b = 2\nprint a + b"},
+			},
+		},
+		{
+			name:  "comments only",
+			input: "// nothing to see here",
+			want: []Unit{
+				{Kind: UnitStatements, Text: "// nothing to see here"},
+			},
+		},
+		{
+			name:  "empty input",
+			input: "\n\n",
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitTopLevel(tt.input)
+			if len(got) != len(tt.want) {
+				t.Fatalf("SplitTopLevel() returned %d units, want %d: %#v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("SplitTopLevel() unit %d = %#v, want %#v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestInterfaceSummary(t *testing.T) {
+	units := SplitTopLevel(`function a(x):
+    return x
+print a(1)
+class Stack {
+}`)
+
+	want := "function a(x)\nclass Stack"
+	if got := InterfaceSummary(units); got != want {
+		t.Errorf("InterfaceSummary() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,249 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Usage counts the tokens sent to and generated by a model
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	// Estimated is set when some of the counts came from the tokenizer
+	// because the provider did not report them
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+func (u *Usage) add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.Estimated = u.Estimated || other.Estimated
+}
+
+// estimateUsage counts the tokens of a request and its response with the
+// tokenizer
+func estimateUsage(prompt, response string) Usage {
+	return Usage{PromptTokens: CountTokens(prompt), CompletionTokens: CountTokens(response), Estimated: true}
+}
+
+// usageCounts are the token counts in a usage section. OpenAI-style APIs
+// report prompt and completion tokens, Anthropic input and output tokens.
+type usageCounts struct {
+	PromptTokens     *int `json:"prompt_tokens"`
+	CompletionTokens *int `json:"completion_tokens"`
+	InputTokens      *int `json:"input_tokens"`
+	OutputTokens     *int `json:"output_tokens"`
+}
+
+// responseUsage holds the counts of the response formats we know: a usage
+// section at the top level, or in the message that starts an Anthropic
+// stream, or Ollama's eval counts
+type responseUsage struct {
+	Usage   *usageCounts `json:"usage"`
+	Message *struct {
+		Usage *usageCounts `json:"usage"`
+	} `json:"message"`
+	PromptEvalCount *int `json:"prompt_eval_count"`
+	EvalCount       *int `json:"eval_count"`
+}
+
+func (r responseUsage) counts() (prompt, completion *int) {
+	u := r.Usage
+	if u == nil && r.Message != nil {
+		u = r.Message.Usage
+	}
+	if u == nil {
+		return r.PromptEvalCount, r.EvalCount
+	}
+	return firstOf(u.PromptTokens, u.InputTokens), firstOf(u.CompletionTokens, u.OutputTokens)
+}
+
+// parseUsage reads the token counts a provider reported in a response body.
+// The body may be one JSON object, several as Ollama sends, or a stream of
+// server-sent events; each count is taken from the last object that has it.
+// It reports false when the body has no counts.
+func parseUsage(body []byte) (Usage, bool) {
+	var usage Usage
+	found := false
+
+	for _, object := range jsonObjects(body) {
+		var response responseUsage
+		if err := json.Unmarshal(object, &response); err != nil {
+			continue
+		}
+
+		prompt, completion := response.counts()
+		if prompt != nil {
+			usage.PromptTokens, found = *prompt, true
+		}
+		if completion != nil {
+			usage.CompletionTokens, found = *completion, true
+		}
+	}
+	return usage, found
+}
+
+// jsonObjects splits a response body into its JSON objects: the data of each
+// server-sent event, or the concatenated objects of a plain body
+func jsonObjects(body []byte) [][]byte {
+	var objects [][]byte
+
+	if bytes.HasPrefix(body, []byte("data:")) || bytes.HasPrefix(body, []byte("event:")) {
+		for _, line := range bytes.Split(body, []byte("\n")) {
+			if data, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+				objects = append(objects, bytes.TrimSpace(data))
+			}
+		}
+		return objects
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var object json.RawMessage
+		if err := decoder.Decode(&object); err != nil {
+			return objects
+		}
+		objects = append(objects, object)
+	}
+}
+
+func firstOf(values ...*int) *int {
+	for _, v := range values {
+		if v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// ModelUsage is what one model used during a run
+type ModelUsage struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Requests int    `json:"requests"`
+	Usage
+	// Cost is in US dollars; it is only meaningful when CostKnown is set
+	Cost      float64 `json:"cost"`
+	CostKnown bool    `json:"cost_known"`
+}
+
+// UsageMeter adds up the usage of every model called during a run. It is
+// safe for concurrent use.
+type UsageMeter struct {
+	// cost prices a request to model; nil leaves every cost unknown
+	cost func(model string, promptTokens, completionTokens int) (float64, bool)
+
+	mu     sync.Mutex
+	models []*ModelUsage
+}
+
+// record adds one request to model at provider
+func (m *UsageMeter) record(provider, model string, usage Usage) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var entry *ModelUsage
+	for _, existing := range m.models {
+		if existing.Provider == provider && existing.Model == model {
+			entry = existing
+			break
+		}
+	}
+	if entry == nil {
+		entry = &ModelUsage{Provider: provider, Model: model, CostKnown: true}
+		m.models = append(m.models, entry)
+	}
+
+	entry.Requests++
+	entry.add(usage)
+	if m.cost == nil {
+		entry.CostKnown = false
+		return
+	}
+	cost, ok := m.cost(model, usage.PromptTokens, usage.CompletionTokens)
+	entry.Cost += cost
+	entry.CostKnown = entry.CostKnown && ok
+}
+
+// Cost returns what the run has cost so far, leaving out models whose
+// prices are unknown
+func (m *UsageMeter) Cost() float64 {
+	if m == nil {
+		return 0
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var cost float64
+	for _, entry := range m.models {
+		cost += entry.Cost
+	}
+	return cost
+}
+
+// Models returns the usage of each model, in the order they were first used
+func (m *UsageMeter) Models() []ModelUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	models := make([]ModelUsage, len(m.models))
+	for i, entry := range m.models {
+		models[i] = *entry
+	}
+	return models
+}
+
+// printStats writes a summary of usage, one line per model and a total when
+// more than one model was used
+func printStats(w io.Writer, models []ModelUsage) {
+	if len(models) == 0 {
+		fmt.Fprintln(w, "Usage: no requests were made")
+		return
+	}
+
+	var total ModelUsage
+	total.CostKnown = true
+	for _, m := range models {
+		fmt.Fprintf(w, "Usage: %s/%s: %s\n", m.Provider, m.Model, formatUsage(m))
+		total.Requests += m.Requests
+		total.add(m.Usage)
+		total.Cost += m.Cost
+		total.CostKnown = total.CostKnown && m.CostKnown
+	}
+	if len(models) > 1 {
+		fmt.Fprintf(w, "Usage: total: %s\n", formatUsage(total))
+	}
+}
+
+func formatUsage(m ModelUsage) string {
+	requests := "requests"
+	if m.Requests == 1 {
+		requests = "request"
+	}
+
+	s := fmt.Sprintf("%d %s, %d prompt + %d completion tokens", m.Requests, requests, m.PromptTokens, m.CompletionTokens)
+	if m.Estimated {
+		s += " (estimated)"
+	}
+	if m.CostKnown {
+		return s + ", " + FormatCost(m.Cost)
+	}
+	return s + ", cost unknown"
+}
+
+// FormatCost formats a cost in US dollars, with more precision for the small
+// amounts a single run usually costs
+func FormatCost(dollars float64) string {
+	if dollars != 0 && dollars < 0.01 {
+		return fmt.Sprintf("$%.4f", dollars)
+	}
+	return fmt.Sprintf("$%.2f", dollars)
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want Usage
+		ok   bool
+	}{
+		{
+			name: "openai",
+			body: `{"choices":[],"usage":{"prompt_tokens":120,"completion_tokens":15,"total_tokens":135}}`,
+			want: Usage{PromptTokens: 120, CompletionTokens: 15},
+			ok:   true,
+		},
+		{
+			name: "anthropic",
+			body: `{"content":[],"usage":{"input_tokens":80,"output_tokens":40}}`,
+			want: Usage{PromptTokens: 80, CompletionTokens: 40},
+			ok:   true,
+		},
+		{
+			name: "ollama stream keeps the final counts",
+			body: "{\"response\":\"a\",\"done\":false}\n{\"response\":\"b\",\"done\":true,\"prompt_eval_count\":30,\"eval_count\":7}\n",
+			want: Usage{PromptTokens: 30, CompletionTokens: 7},
+			ok:   true,
+		},
+		{
+			name: "anthropic stream",
+			body: "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":25,\"output_tokens\":1}}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"text\":\"hi\"}}\n\n" +
+				"event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":12}}\n\n",
+			want: Usage{PromptTokens: 25, CompletionTokens: 12},
+			ok:   true,
+		},
+		{
+			name: "openai stream without usage",
+			body: "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n",
+		},
+		{
+			name: "no usage",
+			body: `{"choices":[]}`,
+		},
+		{
+			name: "not json",
+			body: "upstream connect error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseUsage([]byte(tt.body))
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseUsage() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestUsageMeter(t *testing.T) {
+	meter := &UsageMeter{cost: func(model string, prompt, completion int) (float64, bool) {
+		return float64(prompt+completion) / 1000, model != "local-model"
+	}}
+
+	meter.record("openai", "gpt-4o", Usage{PromptTokens: 100, CompletionTokens: 50})
+	meter.record("openai", "gpt-4o", Usage{PromptTokens: 200, CompletionTokens: 150, Estimated: true})
+	meter.record("ollama", "local-model", Usage{PromptTokens: 10, CompletionTokens: 5})
+
+	models := meter.Models()
+	if len(models) != 2 {
+		t.Fatalf("Models() returned %d models, want 2", len(models))
+	}
+
+	gpt := models[0]
+	if gpt.Model != "gpt-4o" || gpt.Requests != 2 || gpt.PromptTokens != 300 || gpt.CompletionTokens != 200 || !gpt.Estimated {
+		t.Errorf("gpt-4o usage = %+v, want 2 requests, 300 + 200 tokens, estimated", gpt)
+	}
+	if !gpt.CostKnown || gpt.Cost != 0.5 {
+		t.Errorf("gpt-4o cost = %v (known %v), want 0.5", gpt.Cost, gpt.CostKnown)
+	}
+	if models[1].CostKnown {
+		t.Errorf("local-model cost is known, want unknown")
+	}
+
+	var out strings.Builder
+	printStats(&out, models)
+	for _, want := range []string{"openai/gpt-4o: 2 requests, 300 prompt + 200 completion tokens (estimated), $0.50", "cost unknown", "total: 3 requests"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printStats() output %q does not contain %q", out.String(), want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/username/pseudolang/internal/config"
+)
+
+// verifyTimeout bounds a provider verification request
+const verifyTimeout = 15 * time.Second
+
+// VerifyOptions controls how VerifyProvider reaches the provider
+type VerifyOptions struct {
+	// Client sends the request; http.DefaultClient when nil
+	Client *http.Client
+}
+
+// VerifyProvider makes a minimal authenticated request to a provider of the
+// given kind, using its configured address and headers, and reports whether
+// token is accepted
+func VerifyProvider(ctx context.Context, kind, token string, settings config.ProviderConfig, opts VerifyOptions) error {
+	endpoint, err := resolveEndpoint(kind, settings)
+	if err != nil {
+		return err
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.baseURL+endpoint.verifyPath, nil)
+	if err != nil {
+		return fmt.Errorf("invalid API address: %w", err)
+	}
+	if endpoint.authHeader != "" && token != "" {
+		req.Header.Set(endpoint.authHeader, endpoint.authPrefix+token)
+	}
+	for name, value := range endpoint.headers {
+		req.Header.Set(name, value)
+	}
+	query := req.URL.Query()
+	for name, value := range endpoint.params {
+		query.Set(name, value)
+	}
+	req.URL.RawQuery = query.Encode()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", endpoint.baseURL, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%s rejected the token (status %d)", endpoint.baseURL, resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		return fmt.Errorf("%s returned status %d", endpoint.baseURL, resp.StatusCode)
+	}
+	return fmt.Errorf("%s returned status %d: %s", endpoint.baseURL, resp.StatusCode, msg)
+}
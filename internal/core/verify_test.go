@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/username/pseudolang/internal/config"
+)
+
+func TestVerifyProvider(t *testing.T) {
+	var gotPath, gotAuth, gotVersion string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization") + r.Header.Get("x-api-key")
+		gotVersion = r.Header.Get("anthropic-version")
+
+		switch {
+		case strings.HasSuffix(gotAuth, "good"):
+			_, _ = w.Write([]byte(`{"data": []}`))
+		case strings.HasSuffix(gotAuth, "broken"):
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		provider string
+		token    string
+		wantPath string
+		wantAuth string
+		wantErr  string
+	}{
+		{
+			name:     "openai accepts the token",
+			provider: "openai",
+			token:    "good",
+			wantPath: "/models",
+			wantAuth: "Bearer good",
+		},
+		{
+			name:     "anthropic uses its own header",
+			provider: "anthropic",
+			token:    "good",
+			wantPath: "/v1/models",
+			wantAuth: "good",
+		},
+		{
+			name:     "rejected token",
+			provider: "groq",
+			token:    "bad",
+			wantPath: "/models",
+			wantAuth: "Bearer bad",
+			wantErr:  "rejected the token (status 401)",
+		},
+		{
+			name:     "server error",
+			provider: "mistral",
+			token:    "broken",
+			wantPath: "/models",
+			wantAuth: "Bearer broken",
+			wantErr:  "status 503: overloaded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := config.ProviderConfig{BaseURL: server.URL}
+			err := VerifyProvider(context.Background(), tt.provider, tt.token, settings, VerifyOptions{})
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("VerifyProvider() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("VerifyProvider() unexpected error = %v", err)
+			}
+
+			if gotPath != tt.wantPath {
+				t.Errorf("VerifyProvider() path = %q, want %q", gotPath, tt.wantPath)
+			}
+			if gotAuth != tt.wantAuth {
+				t.Errorf("VerifyProvider() auth = %q, want %q", gotAuth, tt.wantAuth)
+			}
+			if tt.provider == "anthropic" && gotVersion == "" {
+				t.Errorf("VerifyProvider() did not send anthropic-version")
+			}
+		})
+	}
+
+	if err := VerifyProvider(context.Background(), "unknown", "good", config.ProviderConfig{BaseURL: server.URL}, VerifyOptions{}); err == nil {
+		t.Errorf("VerifyProvider() expected error for unsupported provider but got none")
+	}
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+// ContextWindowError is returned for a prompt that does not fit in the
+// model's context window. It is refused before it is sent, since the
+// provider would reject or silently truncate it.
+type ContextWindowError struct {
+	Provider string
+	Model    string
+	// Tokens is the length of the prompt and Window the model's limit
+	Tokens int
+	Window int
+}
+
+func (e *ContextWindowError) Error() string {
+	return fmt.Sprintf("the prompt is about %d tokens, over the %d-token context window of %s/%s; translate in smaller pieces with --chunked and --chunk-tokens",
+		e.Tokens, e.Window, e.Provider, e.Model)
+}
+
+// withinContextWindow wraps generate so that prompts longer than window
+// tokens are refused. A window of 0 is unknown and nothing is checked.
+func withinContextWindow(generate generateFunc, provider, model string, window int) generateFunc {
+	if window <= 0 {
+		return generate
+	}
+	return func(ctx context.Context, prompt string) (string, error) {
+		if tokens := CountTokens(prompt); tokens > window {
+			return "", &ContextWindowError{Provider: provider, Model: model, Tokens: tokens, Window: window}
+		}
+		return generate(ctx, prompt)
+	}
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithinContextWindow(t *testing.T) {
+	var calls int
+	generate := func(ctx context.Context, prompt string) (string, error) {
+		calls++
+		return "ok", nil
+	}
+
+	fits := withinContextWindow(generate, "openai", "gpt-4o", 100)
+	if out, err := fits(context.Background(), "print 1"); err != nil || out != "ok" {
+		t.Errorf("generate(short prompt) = %q, %v, want it sent", out, err)
+	}
+
+	var windowErr *ContextWindowError
+	if _, err := fits(context.Background(), strings.Repeat("let x = x + 1\n", 100)); !errors.As(err, &windowErr) || windowErr.Window != 100 {
+		t.Errorf("generate(long prompt) error = %v, want a *ContextWindowError", err)
+	}
+	if calls != 1 {
+		t.Errorf("model called %d times, want only for the prompt that fits", calls)
+	}
+
+	unknown := withinContextWindow(generate, "local", "my-coder", 0)
+	if _, err := unknown(context.Background(), strings.Repeat("let x = x + 1\n", 100)); err != nil {
+		t.Errorf("generate() error = %v, want no check when the window is unknown", err)
+	}
+}
@@ -0,0 +1,194 @@
+// Package logging sets up the structured log used to diagnose runs, and
+// provides spans that time each step of a run
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// logger receives the records of runs; nothing is logged until Setup says
+// where to
+var logger atomic.Pointer[slog.Logger]
+
+// toStderr is set while the log is written to stderr
+var toStderr atomic.Bool
+
+func init() {
+	logger.Store(slog.New(slog.DiscardHandler))
+}
+
+// Logger returns the logger for the steps of a run
+func Logger() *slog.Logger {
+	return logger.Load()
+}
+
+// ToStderr reports whether the log is written to stderr, where it would be
+// mixed with anything else drawn there
+func ToStderr() bool {
+	return toStderr.Load()
+}
+
+// Setup directs the log to file, or to stderr when file is empty, keeping
+// records at level and above. With neither a level nor a file nothing is
+// logged. A file is appended to in JSON, one record per line; stderr gets
+// text. The returned function closes the file.
+func Setup(level, file string) (func() error, error) {
+	toStderr.Store(false)
+	if level == "" && file == "" {
+		logger.Store(slog.New(slog.DiscardHandler))
+		return func() error { return nil }, nil
+	}
+
+	// Asking for a file is asking for a log, so it gets the spans
+	if level == "" {
+		level = "info"
+	}
+	var minLevel slog.Level
+	if err := minLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q (expected debug, info, warn or error)", level)
+	}
+
+	options := &slog.HandlerOptions{Level: minLevel, ReplaceAttr: redactAttr}
+	if file == "" {
+		logger.Store(slog.New(slog.NewTextHandler(os.Stderr, options)))
+		toStderr.Store(true)
+		return func() error { return nil }, nil
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	logger.Store(slog.New(slog.NewJSONHandler(f, options)))
+	return f.Close, nil
+}
+
+// Redacted replaces secrets in log records
+const Redacted = "[REDACTED]"
+
+var (
+	// secretKeyRe matches the names of attributes whose values are secrets
+	secretKeyRe = regexp.MustCompile(`(?i)(token|api[-_]?key|secret|password|authorization)`)
+	// secretValueRe matches secrets in free text: API keys in the formats
+	// providers issue and bearer credentials
+	secretValueRe = regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_-]{8,}|AIza[A-Za-z0-9_-]{20,}|gsk_[A-Za-z0-9]{20,})|(?i:bearer\s+)[A-Za-z0-9._~+/=-]{8,}`)
+
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// minSecretLength keeps short values, which would mangle unrelated text,
+// from being registered as secrets
+const minSecretLength = 8
+
+// AddSecret registers a value, such as a resolved token, to be redacted
+// wherever it appears in the log
+func AddSecret(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, s := range secrets {
+		if s == value {
+			return
+		}
+	}
+	secrets = append(secrets, value)
+}
+
+// Redact replaces the registered secrets and anything that looks like an API
+// key or bearer credential in s
+func Redact(s string) string {
+	secretsMu.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	secretsMu.RUnlock()
+
+	return secretValueRe.ReplaceAllString(s, Redacted)
+}
+
+// IsSecretName reports whether name, of a log attribute or an HTTP header,
+// says that its value is a secret. Counts of tokens are not secrets.
+func IsSecretName(name string) bool {
+	return secretKeyRe.MatchString(name) && !strings.HasSuffix(name, "tokens")
+}
+
+// redactAttr is the handlers' ReplaceAttr: it hides the values of secret
+// attributes and redacts secrets from strings and errors
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if IsSecretName(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+	}
+	return a
+}
+
+type spanKey struct{}
+
+var lastSpanID atomic.Uint64
+
+// Span times one step of a run. Its end is logged with the step's duration,
+// the ID of the span it is part of and any attributes added along the way.
+type Span struct {
+	name    string
+	id      uint64
+	parent  uint64
+	started time.Time
+	attrs   []any
+}
+
+// Start begins a span named name, part of the span ctx carries if any, and
+// returns a context that carries the new span
+func Start(ctx context.Context, name string, attrs ...any) (context.Context, *Span) {
+	span := &Span{name: name, id: lastSpanID.Add(1), started: time.Now(), attrs: attrs}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		span.parent = parent.id
+	}
+
+	Logger().Debug(name+" started", span.ids()...)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Add adds attributes to be logged when the span ends
+func (s *Span) Add(attrs ...any) {
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// End logs the end of the span: at info level when err is nil, and at warn
+// level with the error otherwise
+func (s *Span) End(err error) {
+	attrs := append(s.ids(), "duration_ms", float64(time.Since(s.started).Microseconds())/1000)
+	attrs = append(attrs, s.attrs...)
+
+	if err != nil {
+		Logger().Warn(s.name+" failed", append(attrs, "error", err)...)
+		return
+	}
+	Logger().Info(s.name, attrs...)
+}
+
+func (s *Span) ids() []any {
+	if s.parent == 0 {
+		return []any{"span", s.id}
+	}
+	return []any{"span", s.id, "parent", s.parent}
+}
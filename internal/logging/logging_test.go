@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	AddSecret("local-token-1234")
+	AddSecret("short")
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"registered secret", "dial with local-token-1234 failed", "dial with [REDACTED] failed"},
+		{"short values are not registered", "a short answer", "a short answer"},
+		{"OpenAI key", "key sk-proj-abcdef123456 rejected", "key [REDACTED] rejected"},
+		{"bearer credential", "Authorization: Bearer abc.def-ghi_jkl", "Authorization: [REDACTED]"},
+		{"no secrets", "status 503 Service Unavailable", "status 503 Service Unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// capture directs the log to a buffer for the test
+func capture(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := Logger()
+	logger.Store(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr})))
+	t.Cleanup(func() { logger.Store(previous) })
+	return &buf
+}
+
+func TestRedactAttr(t *testing.T) {
+	buf := capture(t, slog.LevelInfo)
+
+	Logger().Info("request", "api_key", "anything", "token", "x", "prompt_tokens", 12,
+		"error", errors.New("401 for sk-live-0123456789"))
+
+	out := buf.String()
+	for _, want := range []string{"api_key=[REDACTED]", "token=[REDACTED]", "prompt_tokens=12", `error="401 for [REDACTED]"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log = %q, want it to contain %s", out, want)
+		}
+	}
+	if strings.Contains(out, "anything") || strings.Contains(out, "0123456789") {
+		t.Errorf("log = %q, want secrets redacted", out)
+	}
+}
+
+func TestSpan(t *testing.T) {
+	buf := capture(t, slog.LevelDebug)
+
+	ctx, parent := Start(context.Background(), "translation")
+	_, child := Start(ctx, "generation", "model", "gpt-4o")
+	child.Add("prompt_tokens", 10)
+	child.End(errors.New("status 503"))
+	parent.End(nil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("logged %d lines, want a start and an end for each span:\n%s", len(lines), buf.String())
+	}
+	for _, want := range []string{"level=WARN", `msg="generation failed"`, "parent=", "model=gpt-4o", "prompt_tokens=10", `error="status 503"`} {
+		if !strings.Contains(lines[2], want) {
+			t.Errorf("failed span logged %q, want it to contain %s", lines[2], want)
+		}
+	}
+	if !strings.Contains(lines[3], "level=INFO msg=translation") || strings.Contains(lines[3], "parent=") {
+		t.Errorf("top-level span logged %q, want an info line without a parent", lines[3])
+	}
+}
+
+func TestSetup(t *testing.T) {
+	previous := Logger()
+	t.Cleanup(func() { logger.Store(previous) })
+
+	if _, err := Setup("verbose", ""); err == nil {
+		t.Errorf("Setup(invalid level) succeeded, want an error")
+	}
+
+	closeLog, err := Setup("", "")
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	_ = closeLog()
+	if Logger().Enabled(context.Background(), slog.LevelError) || ToStderr() {
+		t.Errorf("logging is enabled without a level or file, want it off")
+	}
+
+	// A level alone logs to stderr
+	t.Cleanup(func() { toStderr.Store(false) })
+	if _, err := Setup("warn", ""); err != nil {
+		t.Fatalf("Setup(level) error = %v", err)
+	}
+	if !ToStderr() {
+		t.Errorf("ToStderr() = false with only a level, want the log on stderr")
+	}
+
+	// A file alone logs the spans, as JSON lines
+	path := filepath.Join(t.TempDir(), "pseudo.log")
+	closeLog, err = Setup("", path)
+	if err != nil {
+		t.Fatalf("Setup(file) error = %v", err)
+	}
+	if ToStderr() {
+		t.Errorf("ToStderr() = true with a log file, want the log in the file only")
+	}
+	_, span := Start(context.Background(), "execution")
+	span.End(nil)
+	if err := closeLog(); err != nil {
+		t.Fatalf("closing the log error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); !strings.Contains(got, `"msg":"execution"`) || strings.Contains(got, "execution started") {
+		t.Errorf("log file = %q, want the span's end at info level only", got)
+	}
+}
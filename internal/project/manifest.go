@@ -0,0 +1,205 @@
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestName is the file name of a project manifest
+const ManifestName = "pseudo.json"
+
+// ErrNoManifest is returned by Find when no manifest exists in the directory
+// or any of its parents
+var ErrNoManifest = errors.New("no " + ManifestName + " found in this directory or any parent")
+
+var validTargets = map[string]bool{
+	"python": true,
+}
+
+// SandboxPolicy restricts how generated code is run
+type SandboxPolicy struct {
+	// Timeout kills the program after this duration, e.g. "30s"
+	Timeout string `json:"timeout,omitempty"`
+	// CleanEnv runs the program without inheriting the caller's environment,
+	// so API keys and other secrets are not visible to generated code
+	CleanEnv bool `json:"clean_env,omitempty"`
+}
+
+// TestSettings configures `pseudo test`
+type TestSettings struct {
+	// Dir holds the test programs, relative to the manifest
+	Dir string `json:"dir,omitempty"`
+	// Pattern selects test files inside Dir
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Manifest is a project file that declares how a pseudolang project is built
+// and run
+type Manifest struct {
+	Name string `json:"name"`
+	// Entry is the file that `pseudo run` and `pseudo build` start from
+	Entry string `json:"entry"`
+	// Sources are the directories holding the project's pseudocode
+	Sources []string `json:"sources,omitempty"`
+	// Output is the directory `pseudo build` writes to
+	Output   string `json:"output,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	// Target is the language generated code is written in
+	Target string `json:"target,omitempty"`
+	// Python is the interpreter used to run generated code
+	Python  string        `json:"python,omitempty"`
+	Sandbox SandboxPolicy `json:"sandbox,omitempty"`
+	Test    TestSettings  `json:"test,omitempty"`
+
+	// Dir is the directory containing the manifest
+	Dir string `json:"-"`
+}
+
+// New returns a manifest for a project called name with the default layout
+func New(name string) *Manifest {
+	return &Manifest{
+		Name:    name,
+		Entry:   "src/main.pseudo",
+		Sources: []string{"src"},
+		Output:  "build",
+		Target:  "python",
+		Test: TestSettings{
+			Dir:     "tests",
+			Pattern: "*.pseudo",
+		},
+	}
+}
+
+// Find walks up from dir looking for a manifest
+func Find(dir string) (*Manifest, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	for {
+		path := filepath.Join(dir, ManifestName)
+		if _, err := os.Stat(path); err == nil {
+			return Load(path)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, ErrNoManifest
+		}
+		dir = parent
+	}
+}
+
+// Load reads and validates the manifest at path
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if err := manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+
+	manifest.Dir = filepath.Dir(path)
+	return &manifest, nil
+}
+
+// Save writes the manifest to its directory
+func (m *Manifest) Save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(m.Dir, ManifestName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	return nil
+}
+
+// Validate checks that the manifest's settings are usable
+func (m *Manifest) Validate() error {
+	if m.Entry == "" {
+		return fmt.Errorf("entry is required")
+	}
+
+	if m.Target != "" && !validTargets[m.Target] {
+		return fmt.Errorf("unsupported target: %s", m.Target)
+	}
+
+	if m.Sandbox.Timeout != "" {
+		if _, err := time.ParseDuration(m.Sandbox.Timeout); err != nil {
+			return fmt.Errorf("invalid sandbox timeout: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Path resolves a path from the manifest relative to the manifest's directory
+func (m *Manifest) Path(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(m.Dir, path)
+}
+
+// EntryPath returns the absolute path of the entry file
+func (m *Manifest) EntryPath() string {
+	return m.Path(m.Entry)
+}
+
+// OutputDir returns the absolute path of the build output directory
+func (m *Manifest) OutputDir() string {
+	if m.Output == "" {
+		return m.Path("build")
+	}
+	return m.Path(m.Output)
+}
+
+// SourceDirs returns the absolute paths of the source directories, which
+// use directives are resolved against
+func (m *Manifest) SourceDirs() []string {
+	dirs := make([]string, len(m.Sources))
+	for i, dir := range m.Sources {
+		dirs[i] = m.Path(dir)
+	}
+	return dirs
+}
+
+// Timeout returns the sandbox timeout, or zero when there is none
+func (m *Manifest) Timeout() time.Duration {
+	timeout, _ := time.ParseDuration(m.Sandbox.Timeout)
+	return timeout
+}
+
+// TestFiles returns the test programs matching the test settings, sorted by name
+func (m *Manifest) TestFiles() ([]string, error) {
+	dir := m.Test.Dir
+	if dir == "" {
+		dir = "tests"
+	}
+	pattern := m.Test.Pattern
+	if pattern == "" {
+		pattern = "*.pseudo"
+	}
+
+	files, err := filepath.Glob(filepath.Join(m.Path(dir), pattern))
+	if err != nil {
+		return nil, fmt.Errorf("invalid test pattern: %w", err)
+	}
+	return files, nil
+}
@@ -0,0 +1,165 @@
+package project
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeManifest(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	writeManifest(t, root, `{"name": "demo", "entry": "src/main.pseudo"}`)
+
+	nested := filepath.Join(root, "src", "lib")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := Find(nested)
+	if err != nil {
+		t.Fatalf("Find() unexpected error = %v", err)
+	}
+	if manifest.Name != "demo" {
+		t.Errorf("Find() name = %q, want demo", manifest.Name)
+	}
+	if manifest.Dir != root {
+		t.Errorf("Find() dir = %q, want %q", manifest.Dir, root)
+	}
+	if want := filepath.Join(root, "src", "main.pseudo"); manifest.EntryPath() != want {
+		t.Errorf("Manifest.EntryPath() = %q, want %q", manifest.EntryPath(), want)
+	}
+}
+
+func TestFindWithoutManifest(t *testing.T) {
+	_, err := Find(t.TempDir())
+	if !errors.Is(err, ErrNoManifest) {
+		t.Errorf("Find() error = %v, want ErrNoManifest", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name:    "valid manifest",
+			content: `{"name": "demo", "entry": "main.pseudo", "target": "python", "sandbox": {"timeout": "30s", "clean_env": true}}`,
+			wantErr: false,
+		},
+		{
+			name:        "missing entry",
+			content:     `{"name": "demo"}`,
+			wantErr:     true,
+			errContains: "entry is required",
+		},
+		{
+			name:        "unsupported target",
+			content:     `{"entry": "main.pseudo", "target": "rust"}`,
+			wantErr:     true,
+			errContains: "unsupported target: rust",
+		},
+		{
+			name:        "invalid timeout",
+			content:     `{"entry": "main.pseudo", "sandbox": {"timeout": "soon"}}`,
+			wantErr:     true,
+			errContains: "invalid sandbox timeout",
+		},
+		{
+			name:        "malformed json",
+			content:     `{"entry": `,
+			wantErr:     true,
+			errContains: "failed to parse",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeManifest(t, dir, tt.content)
+
+			manifest, err := Load(filepath.Join(dir, ManifestName))
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Load() expected error but got none")
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("Load() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Load() unexpected error = %v", err)
+				return
+			}
+			if manifest.Timeout() != 30*time.Second {
+				t.Errorf("Manifest.Timeout() = %v, want 30s", manifest.Timeout())
+			}
+		})
+	}
+}
+
+func TestNewAndSave(t *testing.T) {
+	dir := t.TempDir()
+	manifest := New("demo")
+	manifest.Dir = dir
+
+	if err := manifest.Save(); err != nil {
+		t.Fatalf("Manifest.Save() unexpected error = %v", err)
+	}
+
+	loaded, err := Load(filepath.Join(dir, ManifestName))
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	if loaded.Entry != "src/main.pseudo" || loaded.Target != "python" || loaded.Test.Dir != "tests" {
+		t.Errorf("Load() after Save() = %+v, want the default layout", loaded)
+	}
+	if loaded.OutputDir() != filepath.Join(dir, "build") {
+		t.Errorf("Manifest.OutputDir() = %q, want %q", loaded.OutputDir(), filepath.Join(dir, "build"))
+	}
+}
+
+func TestTestFiles(t *testing.T) {
+	dir := t.TempDir()
+	manifest := New("demo")
+	manifest.Dir = dir
+
+	testsDir := filepath.Join(dir, "tests")
+	if err := os.MkdirAll(testsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"b.pseudo", "a.pseudo", "a.expected"} {
+		if err := os.WriteFile(filepath.Join(testsDir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := manifest.TestFiles()
+	if err != nil {
+		t.Fatalf("Manifest.TestFiles() unexpected error = %v", err)
+	}
+
+	want := []string{filepath.Join(testsDir, "a.pseudo"), filepath.Join(testsDir, "b.pseudo")}
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Errorf("Manifest.TestFiles() = %v, want %v", files, want)
+	}
+}